@@ -2,12 +2,21 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"os"
+	"runtime"
+	"runtime/debug"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
@@ -20,49 +29,117 @@ const tracingName = "go-cctl"
 
 var Logger *zap.SugaredLogger
 
-func SetupLogging() func() {
-	logLevel := zap.NewAtomicLevel()
-	logLevel.SetLevel(zap.InfoLevel)
+// LogLevel is the level of Logger, which can be changed while go-cctl runs.
+//
+// It is also an http.Handler, which reports the level on GET and changes it on PUT, e.g. with {"level":"debug"}.
+var LogLevel = zap.NewAtomicLevel()
+
+// LoggingOptions choose what is logged and how.
+type LoggingOptions struct {
+	// Level is one of "debug", "info", "warn", "error", "dpanic", "panic" or "fatal".
+	Level string
+	// Format is "json", for log shippers, or "console", for humans.
+	Format string
+}
+
+func SetupLogging(options LoggingOptions) (func(), error) {
+	if options.Level != "" {
+		if err := LogLevel.UnmarshalText([]byte(options.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level: %v", options.Level)
+		}
+	}
+
+	var encoder zapcore.Encoder
+	switch options.Format {
+	case "", "json":
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case "console":
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("invalid log format: %v", options.Format)
+	}
+
 	logger := zap.New(zapcore.NewCore(
-		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		encoder,
 		zapcore.Lock(os.Stdout),
-		logLevel,
+		LogLevel,
 	),
 		zap.AddStacktrace(zap.ErrorLevel),
 	)
 
 	Logger = logger.Sugar()
 
-	return func() { Logger.Sync() }
+	return func() { Logger.Sync() }, nil
 }
 
-var ctx context.Context
+// LoggerWithContext returns Logger with the trace id of the span in ctx, if any, so that log entries can be
+// correlated with traces.
+func LoggerWithContext(ctx context.Context) *zap.SugaredLogger {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return Logger
+	}
+
+	return Logger.With("trace_id", spanContext.TraceID().String())
+}
+
+var ctx = context.Background()
+
+// TracingOptions choose where the spans are exported to and how many of them.
+type TracingOptions struct {
+	// Exporter is one of "none", "jaeger", "otlp-grpc", "otlp-http" or "stdout".
+	Exporter string
+	// Endpoint is the host:port of the collector, or empty for the default of the exporter.
+	Endpoint string
+	// Insecure disables TLS towards an OTLP collector.
+	Insecure      bool
+	SamplingRatio float64
+	Environment   string
+}
+
+func SetupTracing(options TracingOptions) func() {
+	// The trace context of callers is propagated even when no spans are exported, so that go-cctl does not break
+	// the traces which pass through it.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{}))
+
+	if options.Exporter == "" || options.Exporter == "none" {
+		return func() {}
+	}
 
-func SetupTracing() func() {
 	_ctx, cancel := context.WithCancel(context.Background())
 
 	ctx = _ctx
 
-	exporter, err := jaeger.New(jaeger.WithAgentEndpoint(
-		jaeger.WithAgentHost("localhost"),
-		jaeger.WithAgentPort("6831"),
-	))
+	exporter, err := newTraceExporter(options)
 	if err != nil {
-		Logger.Error(err)
+		Logger.Errorf("tracing is disabled: %v", err)
+
+		return cancel
+	}
+
+	attributes := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(tracingName),
+		semconv.ServiceVersionKey.String(buildVersion()),
+		semconv.ProcessRuntimeVersionKey.String(runtime.Version()),
+	}
+	if options.Environment != "" {
+		attributes = append(attributes, semconv.DeploymentEnvironmentKey.String(options.Environment))
 	}
 
 	resource, err := resource.Merge(
 		resource.Default(),
-		resource.NewWithAttributes(semconv.SchemaURL,
-			semconv.ServiceNameKey.String(tracingName),
-			semconv.ServiceVersionKey.String("v0.1.0"),
-			attribute.String("environment", "demo")))
+		resource.NewWithAttributes(semconv.SchemaURL, attributes...))
 	if err != nil {
 		panic(err)
 	}
 
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SamplingRatio))),
 		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(resource))
 
@@ -81,6 +158,59 @@ func SetupTracing() func() {
 	}
 }
 
+func newTraceExporter(options TracingOptions) (sdktrace.SpanExporter, error) {
+	switch options.Exporter {
+	case "jaeger":
+		endpoint := options.Endpoint
+		if endpoint == "" {
+			endpoint = "localhost:6831"
+		}
+
+		jaegerHost, jaegerPort, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jaeger endpoint %v: %v", endpoint, err)
+		}
+
+		return jaeger.New(jaeger.WithAgentEndpoint(
+			jaeger.WithAgentHost(jaegerHost),
+			jaeger.WithAgentPort(jaegerPort),
+		))
+	case "otlp-grpc":
+		clientOptions := []otlptracegrpc.Option{}
+		if options.Endpoint != "" {
+			clientOptions = append(clientOptions, otlptracegrpc.WithEndpoint(options.Endpoint))
+		}
+		if options.Insecure {
+			clientOptions = append(clientOptions, otlptracegrpc.WithInsecure())
+		}
+
+		return otlptrace.New(ctx, otlptracegrpc.NewClient(clientOptions...))
+	case "otlp-http":
+		clientOptions := []otlptracehttp.Option{}
+		if options.Endpoint != "" {
+			clientOptions = append(clientOptions, otlptracehttp.WithEndpoint(options.Endpoint))
+		}
+		if options.Insecure {
+			clientOptions = append(clientOptions, otlptracehttp.WithInsecure())
+		}
+
+		return otlptrace.New(ctx, otlptracehttp.NewClient(clientOptions...))
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+
+	return nil, fmt.Errorf("unknown exporter: %v", options.Exporter)
+}
+
+// buildVersion returns the version of the main module, as recorded by the Go toolchain at build time.
+func buildVersion() string {
+	if buildInfo, ok := debug.ReadBuildInfo(); ok && buildInfo.Main.Version != "" {
+		return buildInfo.Main.Version
+	}
+
+	return "(unknown)"
+}
+
 func Span(spanName string) (context.Context, trace.Span) {
 	return otel.Tracer(tracingName).Start(ctx, spanName)
 }
@@ -1,17 +1,17 @@
 package appletUpdater
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"strings"
 	"sync"
 
 	"github.com/sadesyllas/go-cctl/app"
-	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
 	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
 	"github.com/sadesyllas/go-cctl/app/pubsub"
 )
@@ -19,7 +19,7 @@ import (
 var started = false
 var singletonLock sync.Mutex
 
-func Start() {
+func Start(bus *pubsub.Bus) {
 	defer func() { app.Logger.Fatalf("Applet updater has stopped\n") }()
 
 	doStart := func() bool {
@@ -37,74 +37,79 @@ func Start() {
 		return
 	}
 
-	inbound := make(chan pubsub.Message)
-
-	pubsub.Register(pubsub.TopicDeviceState, inbound)
-
-	defaultSource := (*carddevice.CardDevice)(nil)
-
-	for msg := range inbound {
-		if payload, ok := msg.Payload.(*audio.CardsWithDevices); ok {
-			func() {
-				_, span := app.Span("Applet Updater Iteration")
-				defer span.End()
-
-				newDefaultSource := (*carddevice.CardDevice)(nil)
-
-				for _, cardDevice := range payload.Sources {
-					if cardDevice.IsDefault {
-						newDefaultSource = cardDevice
-					}
-				}
-
-				if defaultSource == nil ||
-					(newDefaultSource.Index != defaultSource.Index ||
-						newDefaultSource.Volume != defaultSource.Volume ||
-						newDefaultSource.IsMuted != defaultSource.IsMuted) {
-					defaultSource = newDefaultSource
-
-					var volumeIcon string
-					if newDefaultSource.IsMuted {
-						volumeIcon = "microphone-sensitivity-muted-symbolic"
-					} else if newDefaultSource.Volume < 25.0 {
-						volumeIcon = "microphone-sensitivity-low-symbolic"
-					} else if newDefaultSource.Volume > 75.0 {
-						volumeIcon = "microphone-sensitivity-high-symbolic"
-					} else {
-						volumeIcon = "microphone-sensitivity-medium-symbolic"
-					}
-
-					homeEnvVar, _ := os.LookupEnv("HOME")
-					appFilePathGlob := path.Join(homeEnvVar, ".config", "xfce4", "panel", "**", "*.desktop")
-					appFilePaths, _ := filepath.Glob(appFilePathGlob)
-
-					var appletFilePath string
-					for _, appFilePath := range appFilePaths {
-						content, _ := ioutil.ReadFile(appFilePath)
-						if strings.Contains(string(content), "Name=toggle_microphone") {
-							appletFilePath = appFilePath
-
-							break
-						}
-					}
-
-					if appletFilePath != "" {
-						cmd := exec.Command("sed", "-i", fmt.Sprintf("s/Icon=.*/Icon=%v/", volumeIcon), appletFilePath)
-						cmd.CombinedOutput()
-
-						if !cmd.ProcessState.Success() {
-							app.Logger.Errorf("Could not set the applet icon to %v\n", volumeIcon)
-						}
-					}
-
-					cmd := exec.Command("notify-send", "-t", "1", "-i", volumeIcon, fmt.Sprint(newDefaultSource.Volume))
-					cmd.CombinedOutput()
-
-					if !cmd.ProcessState.Success() {
-						app.Logger.Errorf("Could not notify about new default source state\n")
-					}
-				}
-			}()
+	subscription := bus.Register("applet_updater", pubsub.DropOldest, 8,
+		pubsub.TopicDefaultChanged, pubsub.TopicVolumeChanged, pubsub.TopicMuteChanged)
+	defer subscription.Close()
+
+	for msg := range subscription.C() {
+		var t carddevice.CardDeviceType
+		var cardDevice *carddevice.CardDevice
+
+		if payload, ok := event.DefaultChangedTopic.Payload(msg); ok {
+			t, cardDevice = payload.Type, payload.Device
+		} else if payload, ok := event.VolumeChangedTopic.Payload(msg); ok {
+			t, cardDevice = payload.Type, payload.Device
+		} else if payload, ok := event.MuteChangedTopic.Payload(msg); ok {
+			t, cardDevice = payload.Type, payload.Device
+		} else {
+			continue
+		}
+
+		if t != carddevice.Source || !cardDevice.IsDefault {
+			continue
+		}
+
+		func() {
+			ctx, span := app.SpanWithContext(msg.Context(), "Applet Updater Iteration")
+			defer span.End()
+
+			updateApplet(cardDevice, ctx)
+		}()
+	}
+}
+
+func updateApplet(defaultSource *carddevice.CardDevice, ctx context.Context) {
+	logger := app.LoggerWithContext(ctx).With(
+		"type", carddevice.Source, "index", defaultSource.Index, "name", defaultSource.Name)
+
+	appletConfig := config.Get().Applet
+
+	var volumeIcon string
+	if defaultSource.IsMuted {
+		volumeIcon = "microphone-sensitivity-muted-symbolic"
+	} else if defaultSource.Volume < appletConfig.LowThreshold {
+		volumeIcon = "microphone-sensitivity-low-symbolic"
+	} else if defaultSource.Volume > appletConfig.HighThreshold {
+		volumeIcon = "microphone-sensitivity-high-symbolic"
+	} else {
+		volumeIcon = "microphone-sensitivity-medium-symbolic"
+	}
+
+	appFilePaths, _ := filepath.Glob(appletConfig.PanelGlob)
+
+	var appletFilePath string
+	for _, appFilePath := range appFilePaths {
+		content, _ := ioutil.ReadFile(appFilePath)
+		if strings.Contains(string(content), "Name=toggle_microphone") {
+			appletFilePath = appFilePath
+
+			break
 		}
 	}
+
+	if appletFilePath != "" {
+		cmd := exec.Command("sed", "-i", fmt.Sprintf("s/Icon=.*/Icon=%v/", volumeIcon), appletFilePath)
+		cmd.CombinedOutput()
+
+		if !cmd.ProcessState.Success() {
+			logger.Errorw("Could not set the applet icon", "icon", volumeIcon, "path", appletFilePath)
+		}
+	}
+
+	cmd := exec.Command("notify-send", "-t", "1", "-i", volumeIcon, fmt.Sprint(defaultSource.Volume))
+	cmd.CombinedOutput()
+
+	if !cmd.ProcessState.Success() {
+		logger.Errorw("Could not notify about the new default source state")
+	}
 }
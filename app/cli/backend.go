@@ -0,0 +1,385 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/scene"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/web"
+)
+
+// backend carries out the commands, either through a running daemon or directly through pacmd.
+type backend interface {
+	list() (*audio.CardsWithDevices, error)
+	setVolume(request web.VolumeRequest) error
+	stepVolume(request web.VolumeStepRequest) error
+	setMute(request web.MuteRequest) error
+	toggleMute(request web.MuteToggleRequest) error
+	setDefault(request web.DefaultCardDeviceRequest) error
+	setProfile(request web.CardProfileRequest) error
+	applyScene(name string) error
+	watch(fn func(*audio.CardsWithDevices)) error
+}
+
+const requestTimeout = 10 * time.Second
+
+type remoteBackend struct {
+	server    string
+	token     string
+	transport http.RoundTripper
+}
+
+// newRemoteBackend accepts an HTTP(S) URL or unix:// followed by the path of the unix socket of the daemon.
+func newRemoteBackend(server string, token string) *remoteBackend {
+	if strings.HasPrefix(server, "unix://") {
+		socketPath := strings.TrimPrefix(server, "unix://")
+
+		return &remoteBackend{
+			// The host is only needed to form valid URLs.
+			server: "http://go-cctl",
+			token:  token,
+			transport: &http.Transport{
+				DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+					return new(net.Dialer).DialContext(ctx, "unix", socketPath)
+				},
+			},
+		}
+	}
+
+	return &remoteBackend{
+		server:    strings.TrimRight(server, "/"),
+		token:     token,
+		transport: http.DefaultTransport,
+	}
+}
+
+// ping checks that a daemon answers at the server address, through the API document, which is static, unlike the
+// device state, whose requests set off a poll of pacmd.
+func (backend *remoteBackend) ping() error {
+	response, err := backend.do(500*time.Millisecond, http.MethodGet, "/openapi.json", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response)
+	}
+
+	return nil
+}
+
+// isNotRunning reports whether err means that no daemon listens at the server address, rather than that it has
+// failed to answer.
+func isNotRunning(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT)
+}
+
+func (backend *remoteBackend) list() (*audio.CardsWithDevices, error) {
+	response, err := backend.do(requestTimeout, http.MethodGet, "/audio", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, responseError(response)
+	}
+
+	cardsWithDevices := new(audio.CardsWithDevices)
+	if err := json.NewDecoder(response.Body).Decode(cardsWithDevices); err != nil {
+		return nil, fmt.Errorf("could not decode the device state: %v", err)
+	}
+
+	return cardsWithDevices, nil
+}
+
+func (backend *remoteBackend) setVolume(request web.VolumeRequest) error {
+	return backend.send(http.MethodPatch, cardDevicePath(request.Type, request.Index, request.Name),
+		web.CardDevicePatchRequest{Volume: &request.Volume})
+}
+
+func (backend *remoteBackend) stepVolume(request web.VolumeStepRequest) error {
+	return backend.send(http.MethodPost, "/audio/volume/step", request)
+}
+
+func (backend *remoteBackend) setMute(request web.MuteRequest) error {
+	return backend.send(http.MethodPatch, cardDevicePath(request.Type, request.Index, request.Name),
+		web.CardDevicePatchRequest{Muted: &request.Mute})
+}
+
+func (backend *remoteBackend) toggleMute(request web.MuteToggleRequest) error {
+	return backend.send(http.MethodPost, "/audio/mute/toggle", request)
+}
+
+func (backend *remoteBackend) setDefault(request web.DefaultCardDeviceRequest) error {
+	return backend.send(http.MethodPut, "/api/v1/defaults/"+strings.ToLower(request.Type),
+		web.CardDeviceReference{Index: request.Index, Name: request.Name})
+}
+
+func (backend *remoteBackend) setProfile(request web.CardProfileRequest) error {
+	path := "/api/v1/cards/" + url.PathEscape(resourceID(request.Index, request.Name)) + "/profile"
+
+	return backend.send(http.MethodPut, path, web.ProfileRequest{Profile: request.Profile})
+}
+
+func (backend *remoteBackend) applyScene(name string) error {
+	response, err := backend.do(requestTimeout, http.MethodPost, "/api/v1/scenes/"+url.PathEscape(name)+"/apply", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response)
+	}
+
+	return nil
+}
+
+// watch follows the server-sent events stream of the daemon.
+func (backend *remoteBackend) watch(fn func(*audio.CardsWithDevices)) error {
+	// The stream stays open indefinitely, so it cannot share the timeout of the other requests.
+	response, err := backend.do(0, http.MethodGet, "/audio/events", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		cardsWithDevices := new(audio.CardsWithDevices)
+		if err := json.Unmarshal([]byte(data), cardsWithDevices); err != nil {
+			return fmt.Errorf("could not decode the device state: %v", err)
+		}
+
+		fn(cardsWithDevices)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("the event stream has been closed")
+}
+
+func (backend *remoteBackend) send(method string, path string, request interface{}) error {
+	body, _ := json.Marshal(request)
+
+	response, err := backend.do(requestTimeout, method, path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response)
+	}
+
+	return nil
+}
+
+// cardDevicePath returns the path of a source or sink in the versioned API, e.g. /api/v1/sinks/3.
+func cardDevicePath(t string, index uint64, name string) string {
+	return "/api/v1/" + strings.ToLower(t) + "s/" + url.PathEscape(resourceID(index, name))
+}
+
+// resourceID identifies a card device, or card, by name when it is set and by index otherwise.
+func resourceID(index uint64, name string) string {
+	if name == "" {
+		return strconv.FormatUint(index, 10)
+	}
+
+	return name
+}
+
+// do sends a request to the daemon, authenticated with the token, if any, and without a timeout when it is 0.
+func (backend *remoteBackend) do(
+	timeout time.Duration,
+	method string,
+	path string,
+	body io.Reader) (*http.Response, error) {
+	httpRequest, err := http.NewRequest(method, backend.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	if backend.token != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+backend.token)
+	}
+
+	client := &http.Client{Transport: backend.transport, Timeout: timeout}
+
+	return client.Do(httpRequest)
+}
+
+// responseError reports the message of an error response, which is JSON when the request has not passed validation.
+func responseError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(response.Body)
+
+	var errorResponse struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Message != "" {
+		return fmt.Errorf("%v: %v", response.Status, errorResponse.Message)
+	}
+
+	if message := strings.TrimSpace(string(body)); message != "" {
+		return fmt.Errorf("%v: %v", response.Status, message)
+	}
+
+	return fmt.Errorf("%v", response.Status)
+}
+
+// localBackend runs pacmd itself, for when no daemon is running.
+type localBackend struct{}
+
+func (localBackend) list() (*audio.CardsWithDevices, error) {
+	return audio.FetchCardsWithDevices(context.Background()), nil
+}
+
+func (backend localBackend) setVolume(request web.VolumeRequest) error {
+	cardDeviceType, cardDevice, err := backend.findCardDevice(request.Type, request.Index, request.Name)
+	if err != nil {
+		return err
+	}
+
+	return audio.SetVolume(cardDeviceType, cardDevice.Index, request.Volume, context.Background())
+}
+
+func (backend localBackend) stepVolume(request web.VolumeStepRequest) error {
+	cardDeviceType, cardDevice, err := backend.findCardDevice(request.Type, request.Index, request.Name)
+	if err != nil {
+		return err
+	}
+
+	volume := math.Max(math.Min(cardDevice.Volume+request.Step, config.Get().Audio.MaxVolume), 0)
+
+	return audio.SetVolume(cardDeviceType, cardDevice.Index, volume, context.Background())
+}
+
+func (backend localBackend) setMute(request web.MuteRequest) error {
+	cardDeviceType, cardDevice, err := backend.findCardDevice(request.Type, request.Index, request.Name)
+	if err != nil {
+		return err
+	}
+
+	return audio.ToggleMute(cardDeviceType, cardDevice.Index, request.Mute, context.Background())
+}
+
+func (backend localBackend) toggleMute(request web.MuteToggleRequest) error {
+	cardDeviceType, cardDevice, err := backend.findCardDevice(request.Type, request.Index, request.Name)
+	if err != nil {
+		return err
+	}
+
+	return audio.ToggleMute(cardDeviceType, cardDevice.Index, !cardDevice.IsMuted, context.Background())
+}
+
+func (backend localBackend) setDefault(request web.DefaultCardDeviceRequest) error {
+	cardDeviceType, cardDevice, err := backend.findCardDevice(request.Type, request.Index, request.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := audio.SetDefaultCardDevice(cardDeviceType, cardDevice.Index, context.Background()); err != nil {
+		return err
+	}
+
+	audio.MoveAudioClients(cardDeviceType, cardDevice.Index, cardDevice.Name, context.Background())
+
+	return nil
+}
+
+func (localBackend) setProfile(request web.CardProfileRequest) error {
+	name := request.Name
+	if name == "" {
+		name = fmt.Sprint(request.Index)
+	}
+
+	c, err := audio.FetchCardsWithDevices(context.Background()).FindCard(name)
+	if err != nil {
+		return err
+	}
+
+	return audio.SetCardProfile(c.Index, request.Profile, context.Background())
+}
+
+func (localBackend) applyScene(name string) error {
+	_, err := scene.Apply(name, nil, context.Background())
+
+	return err
+}
+
+// watch polls the device state and reports it whenever it changes.
+func (localBackend) watch(fn func(*audio.CardsWithDevices)) error {
+	previous := (*audio.CardsWithDevices)(nil)
+
+	for {
+		cardsWithDevices := audio.FetchCardsWithDevices(context.Background())
+
+		if !reflect.DeepEqual(previous, cardsWithDevices) {
+			fn(cardsWithDevices)
+
+			previous = cardsWithDevices
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (localBackend) findCardDevice(
+	t string,
+	index uint64,
+	name string) (carddevice.CardDeviceType, *carddevice.CardDevice, error) {
+	cardDeviceType, err := carddevice.ParseableCardDeviceType(t).Parse()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if name == "" {
+		name = fmt.Sprint(index)
+	}
+
+	cardDevice, err := audio.FetchCardsWithDevices(context.Background()).FindCardDevice(cardDeviceType, name)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return cardDeviceType, cardDevice, nil
+}
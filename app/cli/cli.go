@@ -0,0 +1,334 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/web"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultServer = "http://localhost:3003"
+
+const usage = `Usage:
+  go-cctl [flags] list
+  go-cctl [flags] volume <source|sink> [device] <percentage|+step|-step>
+  go-cctl [flags] mute <source|sink> [device] <on|off|toggle>
+  go-cctl [flags] default <source|sink> <device>
+  go-cctl [flags] profile <card> <profile>
+  go-cctl [flags] scene <name>
+  go-cctl [flags] watch
+
+A device is an index, a name, a description pattern or @default, which is also used when it is omitted.
+A card is an index, a name or a description pattern.
+
+Without --server, pacmd is run directly when no daemon is running.
+
+Flags:
+`
+
+var commands = map[string]func(backend, []string, bool) error{
+	"list":    list,
+	"volume":  volume,
+	"mute":    mute,
+	"default": setDefault,
+	"profile": profile,
+	"scene":   applyScene,
+	"watch":   watch,
+}
+
+// numberPattern matches the volume arguments, which must not be mistaken for flags.
+var numberPattern = regexp.MustCompile(`^[+-]?[0-9]+(?:\.[0-9]+)?$`)
+
+// IsCommand reports whether the arguments name a command line client subcommand, instead of starting the daemon.
+func IsCommand(args []string) bool {
+	_, positionalArgs := splitArgs(args)
+	if len(positionalArgs) == 0 {
+		return false
+	}
+
+	_, ok := commands[positionalArgs[0]]
+
+	return ok
+}
+
+// Run runs the subcommand named in the arguments and returns the exit code of the process.
+func Run(args []string) int {
+	flags := pflag.NewFlagSet("go-cctl", pflag.ContinueOnError)
+	server := flags.String("server", envOrDefault("GO_CCTL_SERVER", ""),
+		"The address of the daemon, an HTTP(S) URL or unix:// followed by a socket path (default: its unix socket, "+
+			"or else "+defaultServer+")")
+	token := flags.String("token", envOrDefault("GO_CCTL_TOKEN", ""), "The token to authenticate to the daemon with")
+	local := flags.Bool("local", false, "Run pacmd directly instead of going through the daemon")
+	jsonOutput := flags.Bool("json", false, "Print JSON instead of tables")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	flagArgs, positionalArgs := splitArgs(args)
+	if err := flags.Parse(flagArgs); err != nil {
+		return 2
+	}
+
+	if len(positionalArgs) == 0 {
+		flags.Usage()
+
+		return 2
+	}
+
+	command, ok := commands[positionalArgs[0]]
+	if !ok {
+		flags.Usage()
+
+		return 2
+	}
+
+	app.Logger = zap.New(zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stderr),
+		zap.WarnLevel,
+	)).Sugar()
+
+	if err := config.Load(config.DefaultPath(), config.ApplyEnv); err != nil {
+		app.Logger.Warn(err)
+	}
+
+	// Only the default server address may turn out to have no daemon behind it, in which case pacmd is run directly.
+	serverGiven := *server != ""
+	if !serverGiven {
+		*server = defaultServer
+		if socket := config.Get().Server.Socket; socket != "" {
+			if _, err := os.Stat(socket); err == nil {
+				*server = "unix://" + socket
+			}
+		}
+	}
+
+	var b backend = localBackend{}
+	if !*local {
+		remote := newRemoteBackend(*server, *token)
+		if err := remote.ping(); err == nil {
+			b = remote
+		} else if serverGiven || !isNotRunning(err) {
+			fmt.Fprintf(os.Stderr, "go-cctl: could not reach the daemon at %v: %v\n", *server, err)
+
+			return 1
+		}
+	}
+
+	if err := command(b, positionalArgs[1:], *jsonOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "go-cctl: %v\n", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func list(b backend, args []string, jsonOutput bool) error {
+	if len(args) != 0 {
+		return fmt.Errorf("list takes no arguments")
+	}
+
+	cardsWithDevices, err := b.list()
+	if err != nil {
+		return err
+	}
+
+	printCardsWithDevices(os.Stdout, cardsWithDevices, jsonOutput)
+
+	return nil
+}
+
+func volume(b backend, args []string, _ bool) error {
+	t, device, value, err := parseCardDeviceArgs("volume", args)
+	if err != nil {
+		return err
+	}
+
+	if !numberPattern.MatchString(value) {
+		return fmt.Errorf("invalid volume: %v", value)
+	}
+
+	amount, _ := strconv.ParseFloat(value, 64)
+
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		return b.stepVolume(web.VolumeStepRequest{Type: t, Name: device, Step: amount})
+	}
+
+	return b.setVolume(web.VolumeRequest{Type: t, Name: device, Volume: amount})
+}
+
+func mute(b backend, args []string, _ bool) error {
+	t, device, value, err := parseCardDeviceArgs("mute", args)
+	if err != nil {
+		return err
+	}
+
+	switch value {
+	case "on":
+		return b.setMute(web.MuteRequest{Type: t, Name: device, Mute: true})
+	case "off":
+		return b.setMute(web.MuteRequest{Type: t, Name: device, Mute: false})
+	case "toggle":
+		return b.toggleMute(web.MuteToggleRequest{Type: t, Name: device})
+	}
+
+	return fmt.Errorf("invalid mute value: %v", value)
+}
+
+func setDefault(b backend, args []string, _ bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: default <source|sink> <device>")
+	}
+
+	if _, err := carddevice.ParseableCardDeviceType(args[0]).Parse(); err != nil {
+		return err
+	}
+
+	return b.setDefault(web.DefaultCardDeviceRequest{Type: args[0], Name: args[1]})
+}
+
+func profile(b backend, args []string, _ bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: profile <card> <profile>")
+	}
+
+	cardProfile, err := card.ParseableProfile(args[1]).Parse()
+	if err != nil {
+		return err
+	}
+
+	return b.setProfile(web.CardProfileRequest{Name: args[0], Profile: cardProfile})
+}
+
+func applyScene(b backend, args []string, _ bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: scene <name>")
+	}
+
+	return b.applyScene(args[0])
+}
+
+func watch(b backend, args []string, jsonOutput bool) error {
+	if len(args) != 0 {
+		return fmt.Errorf("watch takes no arguments")
+	}
+
+	return b.watch(func(cardsWithDevices *audio.CardsWithDevices) {
+		if !jsonOutput {
+			fmt.Fprintf(os.Stdout, "--- %v\n", time.Now().Format(time.RFC3339))
+		}
+
+		printCardsWithDevices(os.Stdout, cardsWithDevices, jsonOutput)
+	})
+}
+
+// parseCardDeviceArgs parses "<source|sink> [device] <value>", where the device defaults to the default one.
+func parseCardDeviceArgs(command string, args []string) (t string, device string, value string, err error) {
+	switch len(args) {
+	case 2:
+		t, device, value = args[0], audio.DefaultAlias, args[1]
+	case 3:
+		t, device, value = args[0], args[1], args[2]
+	default:
+		err = fmt.Errorf("usage: %v <source|sink> [device] <value>", command)
+
+		return
+	}
+
+	_, err = carddevice.ParseableCardDeviceType(t).Parse()
+
+	return
+}
+
+func printCardsWithDevices(w io.Writer, cardsWithDevices *audio.CardsWithDevices, jsonOutput bool) {
+	if jsonOutput {
+		json.NewEncoder(w).Encode(cardsWithDevices)
+
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "TYPE\tINDEX\tDEFAULT\tVOLUME\tMUTED\tNAME\tDESCRIPTION")
+	printCardDevices(tw, carddevice.Source, cardsWithDevices.Sources)
+	printCardDevices(tw, carddevice.Sink, cardsWithDevices.Sinks)
+	tw.Flush()
+
+	if len(cardsWithDevices.Cards) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "CARD\tPROFILE\tNAME\tDESCRIPTION")
+	for _, c := range cardsWithDevices.Cards {
+		activeProfile := "-"
+		if c.ActiveProfile != card.UnknownProfile {
+			activeProfile = c.ActiveProfile.String()
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", c.Index, activeProfile, c.Name, c.Description)
+	}
+	tw.Flush()
+}
+
+func printCardDevices(w io.Writer, t carddevice.CardDeviceType, cardDevices []*carddevice.CardDevice) {
+	for _, cardDevice := range cardDevices {
+		isDefault, isMuted := "", "no"
+		if cardDevice.IsDefault {
+			isDefault = "*"
+		}
+		if cardDevice.IsMuted {
+			isMuted = "yes"
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v%%\t%v\t%v\t%v\n",
+			t, cardDevice.Index, isDefault, cardDevice.Volume, isMuted, cardDevice.Name, cardDevice.Description)
+	}
+}
+
+// splitArgs separates the flags from the positional arguments, leaving negative volume steps with the latter.
+func splitArgs(args []string) (flagArgs []string, positionalArgs []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if !strings.HasPrefix(arg, "-") || numberPattern.MatchString(arg) {
+			positionalArgs = append(positionalArgs, arg)
+
+			continue
+		}
+
+		flagArgs = append(flagArgs, arg)
+
+		if (arg == "--server" || arg == "--token") && i+1 < len(args) {
+			flagArgs = append(flagArgs, args[i+1])
+			i++
+		}
+	}
+
+	return
+}
+
+func envOrDefault(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return value
+	}
+
+	return defaultValue
+}
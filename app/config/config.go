@@ -0,0 +1,531 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of go-cctl, as read from a YAML file, e.g.
+//
+//	server:
+//	  port: 3003
+//	monitor:
+//	  pollInterval: 15s
+//	exclusions:
+//	  audioClients: [PulseAudio Volume Control]
+//	scenes:
+//	  meeting:
+//	    defaultSource: "*headset*"
+//	    defaultSink: "*headset*"
+//	    devices:
+//	      - {type: source, id: "*headset*", volume: 80, muted: false}
+//	    profiles:
+//	      - {card: "*headset*", profile: headset_head_unit}
+//	rules:
+//	  - {on: device_added, type: sink, name: bluez_sink.*, scene: meeting}
+//
+// Settings which are only read on startup, i.e. the server address and the tracing settings, need a restart to take
+// effect. Everything else is applied as soon as the file changes.
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Monitor    MonitorConfig    `yaml:"monitor"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Audio      AudioConfig      `yaml:"audio"`
+	Applet     AppletConfig     `yaml:"applet"`
+	Exclusions ExclusionsConfig `yaml:"exclusions"`
+	// Scenes are named states of the devices, which are applied on request or by the rules.
+	Scenes map[string]SceneConfig `yaml:"scenes"`
+	Rules  []RuleConfig           `yaml:"rules"`
+}
+
+type ServerConfig struct {
+	Port uint16 `yaml:"port"`
+	// Listen is a host:port or the path of a unix socket, which takes precedence over the port.
+	Listen string `yaml:"listen"`
+	// Socket is the path of a unix socket which is listened on next to the TCP port, or empty for none.
+	Socket string `yaml:"socket"`
+	// SocketMode is the octal file mode of the socket, which decides who may use it, e.g. "0660" for the group of
+	// the user too.
+	SocketMode string     `yaml:"socketMode"`
+	TLS        TLSConfig  `yaml:"tls"`
+	CORS       CORSConfig `yaml:"cors"`
+}
+
+// ListenAddresses returns the TCP address and the unix socket paths to listen on, which may be none.
+func (serverConfig ServerConfig) ListenAddresses() []string {
+	addresses := []string{}
+
+	if address := serverConfig.ListenAddress(); address != "" {
+		addresses = append(addresses, address)
+	}
+
+	if serverConfig.Socket != "" && serverConfig.Socket != serverConfig.Listen {
+		addresses = append(addresses, serverConfig.Socket)
+	}
+
+	return addresses
+}
+
+// ListenAddress returns the address to listen on, which is a unix socket when it contains a slash.
+func (serverConfig ServerConfig) ListenAddress() string {
+	if serverConfig.Listen != "" {
+		return serverConfig.Listen
+	}
+
+	if serverConfig.Port == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(":%v", serverConfig.Port)
+}
+
+// TLSConfig enables HTTPS and WSS on the TCP listener, next to plain HTTP, which is then only served to localhost.
+type TLSConfig struct {
+	// CertFile and KeyFile are PEM files, which default to tls.crt and tls.key next to the configuration file when
+	// SelfSigned is set.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// SelfSigned generates a self-signed certificate on first run, unless its files already exist.
+	SelfSigned bool `yaml:"selfSigned"`
+}
+
+func (tlsConfig TLSConfig) Enabled() bool {
+	return tlsConfig.CertFile != "" || tlsConfig.SelfSigned
+}
+
+// CORSConfig is the policy towards web pages served from other origins, e.g. the web UI.
+type CORSConfig struct {
+	// AllowedOrigins are shell patterns, e.g. "http://192.168.1.*:3005", or "*" for any origin. Requests from
+	// other origins are rejected, except for those from the pages of the server itself, e.g. the web UI.
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+	AllowedMethods []string `yaml:"allowedMethods"`
+	AllowedHeaders []string `yaml:"allowedHeaders"`
+}
+
+// AuthConfig lists the credentials accepted by the web server, each with the role it grants, i.e. "read", which
+// only allows reading the device state, or "control", which also allows changing it.
+//
+// Requests without credentials are only accepted from localhost, where they are granted the control role, unless
+// they come from a web page of an origin which is not listed in server.cors.allowedOrigins by name.
+type AuthConfig struct {
+	// Tokens are accepted as bearer tokens and, for websockets, as the token query parameter or in the first
+	// message.
+	Tokens []TokenConfig `yaml:"tokens"`
+	// Users are accepted through HTTP basic auth.
+	Users []UserConfig `yaml:"users"`
+}
+
+type TokenConfig struct {
+	Token string `yaml:"token"`
+	Role  string `yaml:"role"`
+}
+
+type UserConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Role     string `yaml:"role"`
+}
+
+type MonitorConfig struct {
+	PollInterval time.Duration `yaml:"pollInterval"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of "none", "jaeger", "otlp-grpc", "otlp-http" or "stdout".
+	Exporter      string  `yaml:"exporter"`
+	Endpoint      string  `yaml:"endpoint"`
+	Insecure      bool    `yaml:"insecure"`
+	SamplingRatio float64 `yaml:"samplingRatio"`
+	Environment   string  `yaml:"environment"`
+}
+
+type AudioConfig struct {
+	MaxVolume float64 `yaml:"maxVolume"`
+}
+
+type AppletConfig struct {
+	PanelGlob     string  `yaml:"panelGlob"`
+	LowThreshold  float64 `yaml:"lowThreshold"`
+	HighThreshold float64 `yaml:"highThreshold"`
+}
+
+// ExclusionsConfig lists what go-cctl leaves alone, by shell patterns.
+type ExclusionsConfig struct {
+	// AudioClients are never moved to the default card device.
+	AudioClients []string `yaml:"audioClients"`
+	// CardDevices are left out of the device state, by name.
+	CardDevices []string `yaml:"cardDevices"`
+}
+
+// SceneConfig is a state of the devices, where the cards and the card devices are identified like in the API, i.e.
+// by an index, a name or a case insensitive description pattern. Those which are not present are left out.
+type SceneConfig struct {
+	// DefaultSource and DefaultSink become the default card devices, to which every stream is moved, unless empty.
+	DefaultSource string `yaml:"defaultSource"`
+	DefaultSink   string `yaml:"defaultSink"`
+	// Devices are set after the profiles and the default card devices, and may refer to the latter as @default.
+	Devices  []SceneDeviceConfig  `yaml:"devices"`
+	Profiles []SceneProfileConfig `yaml:"profiles"`
+}
+
+type SceneDeviceConfig struct {
+	// Type is source or sink.
+	Type string `yaml:"type"`
+	ID   string `yaml:"id"`
+	// Volume and Muted are left as they are when not set.
+	Volume *float64 `yaml:"volume"`
+	Muted  *bool    `yaml:"muted"`
+}
+
+type SceneProfileConfig struct {
+	Card    string `yaml:"card"`
+	Profile string `yaml:"profile"`
+}
+
+// RuleConfig applies a scene whenever a source or sink is added, removed or made the default one, including the
+// card devices which are present when go-cctl starts, which are reported as added.
+//
+// A scene which changes the default card devices sets off the default_changed rules in turn, which is why a rule
+// is left alone for a while after it has been applied.
+type RuleConfig struct {
+	// On is device_added, device_removed or default_changed.
+	On string `yaml:"on"`
+	// Type is source or sink, or empty for both.
+	Type string `yaml:"type"`
+	// Name is a shell pattern of the names of the card devices, or empty for any.
+	Name  string `yaml:"name"`
+	Scene string `yaml:"scene"`
+}
+
+func Default() *Config {
+	homeEnvVar, _ := os.LookupEnv("HOME")
+
+	return &Config{
+		Server: ServerConfig{
+			Socket:     defaultSocket(),
+			SocketMode: "0600",
+			CORS: CORSConfig{
+				AllowedOrigins: []string{},
+				AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders: []string{"Content-Type", "Authorization", "Traceparent", "Tracestate"},
+			},
+		},
+		Monitor: MonitorConfig{
+			PollInterval: 15 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter:      "none",
+			SamplingRatio: 1,
+		},
+		Audio: AudioConfig{
+			MaxVolume: 100,
+		},
+		Applet: AppletConfig{
+			PanelGlob:     filepath.Join(homeEnvVar, ".config", "xfce4", "panel", "**", "*.desktop"),
+			LowThreshold:  25,
+			HighThreshold: 75,
+		},
+		Exclusions: ExclusionsConfig{
+			AudioClients: []string{"PulseAudio Volume Control"},
+		},
+	}
+}
+
+// defaultSocket returns $XDG_RUNTIME_DIR/go-cctl.sock, or no socket when there is no runtime directory.
+func defaultSocket() string {
+	runtimeDir, ok := os.LookupEnv("XDG_RUNTIME_DIR")
+	if !ok || runtimeDir == "" {
+		return ""
+	}
+
+	return filepath.Join(runtimeDir, "go-cctl.sock")
+}
+
+func (config *Config) validate() error {
+	for _, token := range config.Auth.Tokens {
+		if token.Token == "" {
+			return fmt.Errorf("auth.tokens must not be empty")
+		}
+
+		if token.Role != "read" && token.Role != "control" {
+			return fmt.Errorf("auth.tokens roles must be read or control")
+		}
+	}
+
+	for _, user := range config.Auth.Users {
+		if user.Username == "" || user.Password == "" {
+			return fmt.Errorf("auth.users must have a username and a password")
+		}
+
+		if user.Role != "read" && user.Role != "control" {
+			return fmt.Errorf("auth.users roles must be read or control")
+		}
+	}
+
+	if config.Monitor.PollInterval < time.Second {
+		return fmt.Errorf("monitor.pollInterval must be at least 1s")
+	}
+
+	switch config.Tracing.Exporter {
+	case "none", "jaeger", "otlp-grpc", "otlp-http", "stdout":
+	default:
+		return fmt.Errorf("tracing.exporter must be one of none, jaeger, otlp-grpc, otlp-http or stdout")
+	}
+
+	if config.Tracing.SamplingRatio < 0 || config.Tracing.SamplingRatio > 1 {
+		return fmt.Errorf("tracing.samplingRatio must be between 0 and 1")
+	}
+
+	if config.Audio.MaxVolume <= 0 {
+		return fmt.Errorf("audio.maxVolume must be positive")
+	}
+
+	if config.Applet.LowThreshold < 0 ||
+		config.Applet.LowThreshold > config.Applet.HighThreshold ||
+		config.Applet.HighThreshold > 100 {
+		return fmt.Errorf("applet thresholds must satisfy 0 <= lowThreshold <= highThreshold <= 100")
+	}
+
+	if _, err := strconv.ParseUint(config.Server.SocketMode, 8, 32); err != nil {
+		return fmt.Errorf("server.socketMode must be an octal file mode, e.g. 0600")
+	}
+
+	if (config.Server.TLS.CertFile == "") != (config.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls.certFile and server.tls.keyFile must be set together")
+	}
+
+	for _, pattern := range config.Server.CORS.AllowedOrigins {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed origin pattern: %v", pattern)
+		}
+	}
+
+	for _, patterns := range [][]string{config.Exclusions.AudioClients, config.Exclusions.CardDevices} {
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid exclusion pattern: %v", pattern)
+			}
+		}
+	}
+
+	for name, sceneConfig := range config.Scenes {
+		if err := sceneConfig.validate(config.Audio.MaxVolume); err != nil {
+			return fmt.Errorf("scenes.%v: %v", name, err)
+		}
+	}
+
+	for _, ruleConfig := range config.Rules {
+		switch ruleConfig.On {
+		case "device_added", "device_removed", "default_changed":
+		default:
+			return fmt.Errorf("rules must be on device_added, device_removed or default_changed")
+		}
+
+		if ruleConfig.Type != "" && ruleConfig.Type != "source" && ruleConfig.Type != "sink" {
+			return fmt.Errorf("rules types must be source or sink")
+		}
+
+		if _, err := filepath.Match(ruleConfig.Name, ""); err != nil {
+			return fmt.Errorf("invalid rule name pattern: %v", ruleConfig.Name)
+		}
+
+		if _, ok := config.Scenes[ruleConfig.Scene]; !ok {
+			return fmt.Errorf("rules must refer to a scene, not %q", ruleConfig.Scene)
+		}
+	}
+
+	return nil
+}
+
+func (sceneConfig SceneConfig) validate(maxVolume float64) error {
+	for _, deviceConfig := range sceneConfig.Devices {
+		if deviceConfig.Type != "source" && deviceConfig.Type != "sink" {
+			return fmt.Errorf("devices types must be source or sink")
+		}
+
+		if deviceConfig.ID == "" {
+			return fmt.Errorf("devices must have an id")
+		}
+
+		if deviceConfig.Volume != nil && (*deviceConfig.Volume < 0 || *deviceConfig.Volume > maxVolume) {
+			return fmt.Errorf("devices volumes must be between 0 and audio.maxVolume")
+		}
+	}
+
+	for _, profileConfig := range sceneConfig.Profiles {
+		if profileConfig.Card == "" {
+			return fmt.Errorf("profiles must have a card")
+		}
+
+		if _, err := card.ParseableProfile(profileConfig.Profile).Parse(); err != nil {
+			return fmt.Errorf("invalid profile: %v", profileConfig.Profile)
+		}
+	}
+
+	return nil
+}
+
+var current atomic.Value
+var loadLock sync.Mutex
+var path string
+var overrides func(*Config) error
+
+// Get returns the configuration in effect, which is the default one until Load succeeds.
+//
+// The returned configuration must not be modified.
+func Get() *Config {
+	if config, ok := current.Load().(*Config); ok {
+		return config
+	}
+
+	return Default()
+}
+
+// Dir returns the directory of the configuration file, where go-cctl keeps the files it generates.
+func Dir() string {
+	if path == "" {
+		return filepath.Dir(DefaultPath())
+	}
+
+	return filepath.Dir(path)
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/go-cctl/config.yaml.
+func DefaultPath() string {
+	configHome, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	if !ok || configHome == "" {
+		homeEnvVar, _ := os.LookupEnv("HOME")
+		configHome = filepath.Join(homeEnvVar, ".config")
+	}
+
+	return filepath.Join(configHome, "go-cctl", "config.yaml")
+}
+
+// Load reads the configuration from the file at the given path, which may be missing, and applies the
+// overrides, e.g. from flags and environment variables, on top of it.
+//
+// The overrides are applied again on every reload.
+func Load(configPath string, configOverrides func(*Config) error) error {
+	path, overrides = configPath, configOverrides
+
+	return reload()
+}
+
+func reload() error {
+	loadLock.Lock()
+	defer loadLock.Unlock()
+
+	config := Default()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read the configuration file %v: %v", path, err)
+	}
+
+	if err == nil {
+		if err := parse(content, config); err != nil {
+			return fmt.Errorf("could not parse the configuration file %v: %v", path, err)
+		}
+	}
+
+	if overrides != nil {
+		if err := overrides(config); err != nil {
+			return err
+		}
+	}
+
+	if err := config.validate(); err != nil {
+		return fmt.Errorf("invalid configuration in %v: %v", path, err)
+	}
+
+	current.Store(config)
+
+	return nil
+}
+
+// parse decodes content over config, rejecting the keys which name no setting, e.g. misspelt ones, which would
+// otherwise leave their settings at their defaults without a word.
+func parse(content []byte, config *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	// An empty file holds no document at all.
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// Watch reloads the configuration whenever its file changes, keeping the last good configuration in effect when
+// the file cannot be loaded.
+func Watch() {
+	defer func() { app.Logger.Errorf("Configuration watcher has stopped\n") }()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		app.Logger.Errorw("Could not watch the configuration file", "path", path, "error", err)
+
+		return
+	}
+	defer watcher.Close()
+
+	// Editors tend to replace files instead of writing to them, so the directory is watched instead of the file.
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		app.Logger.Errorw("Could not create the configuration directory", "path", dir, "error", err)
+
+		return
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		app.Logger.Errorw("Could not watch the configuration directory", "path", dir, "error", err)
+
+		return
+	}
+
+	// Changes usually arrive as bursts of events, which are debounced into a single reload.
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) == filepath.Clean(path) {
+				debounce.Reset(100 * time.Millisecond)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			app.Logger.Errorw("Error while watching the configuration file", "path", path, "error", err)
+		case <-debounce.C:
+			if err := reload(); err != nil {
+				app.Logger.Errorw("Kept the previous configuration", "path", path, "error", err)
+
+				continue
+			}
+
+			app.Logger.Infow("Reloaded the configuration", "path", path)
+		}
+	}
+}
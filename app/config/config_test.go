@@ -0,0 +1,184 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	volume := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name   string
+		change func(config *Config)
+		want   string
+	}{
+		{"default", func(config *Config) {}, ""},
+		{
+			"token without role",
+			func(config *Config) { config.Auth.Tokens = []TokenConfig{{Token: "secret"}} },
+			"auth.tokens roles must be read or control",
+		},
+		{
+			"user without password",
+			func(config *Config) { config.Auth.Users = []UserConfig{{Username: "user", Role: "read"}} },
+			"auth.users must have a username and a password",
+		},
+		{
+			"short poll interval",
+			func(config *Config) { config.Monitor.PollInterval = time.Millisecond },
+			"monitor.pollInterval must be at least 1s",
+		},
+		{
+			"unknown exporter",
+			func(config *Config) { config.Tracing.Exporter = "zipkin" },
+			"tracing.exporter must be one of",
+		},
+		{
+			"zero max volume",
+			func(config *Config) { config.Audio.MaxVolume = 0 },
+			"audio.maxVolume must be positive",
+		},
+		{
+			"inverted applet thresholds",
+			func(config *Config) { config.Applet.LowThreshold, config.Applet.HighThreshold = 80, 20 },
+			"applet thresholds",
+		},
+		{
+			"decimal socket mode",
+			func(config *Config) { config.Server.SocketMode = "0689" },
+			"server.socketMode must be an octal file mode",
+		},
+		{
+			"certificate without key",
+			func(config *Config) { config.Server.TLS.CertFile = "tls.crt" },
+			"server.tls.certFile and server.tls.keyFile must be set together",
+		},
+		{
+			"invalid origin pattern",
+			func(config *Config) { config.Server.CORS.AllowedOrigins = []string{"http://["} },
+			"invalid allowed origin pattern",
+		},
+		{
+			"invalid exclusion pattern",
+			func(config *Config) { config.Exclusions.CardDevices = []string{"["} },
+			"invalid exclusion pattern",
+		},
+		{
+			"scene",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{
+					"meeting": {
+						DefaultSink: "*headset*",
+						Devices:     []SceneDeviceConfig{{Type: "source", ID: "@default", Volume: volume(80)}},
+						Profiles:    []SceneProfileConfig{{Card: "*headset*", Profile: "headset_head_unit"}},
+					},
+				}
+				config.Rules = []RuleConfig{{On: "device_added", Type: "sink", Name: "bluez_sink.*", Scene: "meeting"}}
+			},
+			"",
+		},
+		{
+			"scene device without type",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{"quiet": {Devices: []SceneDeviceConfig{{ID: "1"}}}}
+			},
+			"scenes.quiet: devices types must be source or sink",
+		},
+		{
+			"scene device without id",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{"quiet": {Devices: []SceneDeviceConfig{{Type: "sink"}}}}
+			},
+			"scenes.quiet: devices must have an id",
+		},
+		{
+			"scene volume above the max volume",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{
+					"loud": {Devices: []SceneDeviceConfig{{Type: "sink", ID: "1", Volume: volume(120)}}},
+				}
+			},
+			"scenes.loud: devices volumes must be between 0 and audio.maxVolume",
+		},
+		{
+			"scene with an unknown profile",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{
+					"quiet": {Profiles: []SceneProfileConfig{{Card: "1", Profile: "a2dp_sink_opus"}}},
+				}
+			},
+			"scenes.quiet: invalid profile: a2dp_sink_opus",
+		},
+		{
+			"rule on an unknown event",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{"quiet": {}}
+				config.Rules = []RuleConfig{{On: "volume_changed", Scene: "quiet"}}
+			},
+			"rules must be on device_added, device_removed or default_changed",
+		},
+		{
+			"rule of an unknown type",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{"quiet": {}}
+				config.Rules = []RuleConfig{{On: "device_added", Type: "card", Scene: "quiet"}}
+			},
+			"rules types must be source or sink",
+		},
+		{
+			"rule with an invalid name pattern",
+			func(config *Config) {
+				config.Scenes = map[string]SceneConfig{"quiet": {}}
+				config.Rules = []RuleConfig{{On: "device_added", Name: "[", Scene: "quiet"}}
+			},
+			"invalid rule name pattern",
+		},
+		{
+			"rule of an unknown scene",
+			func(config *Config) { config.Rules = []RuleConfig{{On: "device_added", Scene: "quiet"}} },
+			`rules must refer to a scene, not "quiet"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Default()
+			test.change(config)
+
+			err := config.validate()
+
+			switch {
+			case test.want == "" && err != nil:
+				t.Errorf("validate() = %v, want no error", err)
+			case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+				t.Errorf("validate() = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"known keys", "audio:\n  maxVolume: 120\nscenes:\n  quiet:\n    defaultSink: '1'\n", false},
+		{"misspelt section", "scene:\n  quiet:\n    defaultSink: '1'\n", true},
+		{"misspelt key", "exclusions:\n  audioclient: [pavucontrol]\n", true},
+		{"invalid YAML", "audio: [", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := parse([]byte(test.content), Default())
+
+			if (err != nil) != test.wantErr {
+				t.Errorf("parse() = %v, want an error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyEnv overrides the configuration with the GO_CCTL_* environment variables which are set.
+func ApplyEnv(config *Config) error {
+	if value, ok := os.LookupEnv("GO_CCTL_PORT"); ok {
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid GO_CCTL_PORT: %v", value)
+		}
+
+		config.Server.Port = uint16(port)
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_LISTEN"); ok {
+		config.Server.Listen = value
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_SOCKET"); ok {
+		config.Server.Socket = value
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_CORS_ORIGINS"); ok {
+		config.Server.CORS.AllowedOrigins = strings.Split(value, ",")
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_POLL_INTERVAL"); ok {
+		pollInterval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid GO_CCTL_POLL_INTERVAL: %v", value)
+		}
+
+		config.Monitor.PollInterval = pollInterval
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_TRACING_EXPORTER"); ok {
+		config.Tracing.Exporter = value
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_TRACING_ENDPOINT"); ok {
+		config.Tracing.Endpoint = value
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_TRACING_SAMPLING_RATIO"); ok {
+		samplingRatio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid GO_CCTL_TRACING_SAMPLING_RATIO: %v", value)
+		}
+
+		config.Tracing.SamplingRatio = samplingRatio
+	}
+
+	if value, ok := os.LookupEnv("GO_CCTL_MAX_VOLUME"); ok {
+		maxVolume, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid GO_CCTL_MAX_VOLUME: %v", value)
+		}
+
+		config.Audio.MaxVolume = maxVolume
+	}
+
+	return nil
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"os/exec"
+	"path/filepath"
 
 	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/config"
 	"github.com/sadesyllas/go-cctl/app/device/pacmd/audioclient"
 	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
 	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
@@ -18,10 +19,44 @@ type CardsWithDevices struct {
 	Cards   []*card.Card             `json:"cards"`
 	Sources []*carddevice.CardDevice `json:"sources"`
 	Sinks   []*carddevice.CardDevice `json:"sinks"`
+	// Fetched reports which of the sections above could be fetched, since one which could not is left empty,
+	// rather than having no cards or devices.
+	Fetched Fetched `json:"-"`
 }
 
-func FetchCardsWithDevices() *CardsWithDevices {
-	ctx, span := app.Span("FetchDevices")
+type Fetched struct {
+	Cards   bool
+	Sources bool
+	Sinks   bool
+}
+
+// KeepFetched returns the current snapshot, with the sections which could not be fetched taken from the previous
+// one, so that a section which has failed to fetch is not mistaken for one with no cards or devices. A nil previous
+// snapshot leaves the current one as it is.
+func KeepFetched(previous, current *CardsWithDevices) *CardsWithDevices {
+	if previous == nil {
+		return current
+	}
+
+	kept := *current
+
+	if !current.Fetched.Cards {
+		kept.Cards, kept.Fetched.Cards = previous.Cards, previous.Fetched.Cards
+	}
+
+	if !current.Fetched.Sources {
+		kept.Sources, kept.Fetched.Sources = previous.Sources, previous.Fetched.Sources
+	}
+
+	if !current.Fetched.Sinks {
+		kept.Sinks, kept.Fetched.Sinks = previous.Sinks, previous.Fetched.Sinks
+	}
+
+	return &kept
+}
+
+func FetchCardsWithDevices(ctx context.Context) *CardsWithDevices {
+	ctx, span := app.SpanWithContext(ctx, "FetchDevices")
 	defer span.End()
 
 	cardsCh := make(chan types.CommandResultCards)
@@ -39,23 +74,57 @@ func FetchCardsWithDevices() *CardsWithDevices {
 
 	result := new(CardsWithDevices)
 
+	result.Fetched = Fetched{
+		Cards:   resultCards.Success,
+		Sources: resultSources.Success,
+		Sinks:   resultSinks.Success,
+	}
+
 	if resultCards.Success {
 		result.Cards = resultCards.Cards
 	}
 
 	if resultSources.Success {
-		result.Sources = resultSources.CardDevices
+		result.Sources = excludeCardDevices(resultSources.CardDevices)
 	}
 
 	if resultSinks.Success {
-		result.Sinks = resultSinks.CardDevices
+		result.Sinks = excludeCardDevices(resultSinks.CardDevices)
 	}
 
+	inheritBatteryLevels(result)
+
 	return result
 }
 
-func SetVolume(t carddevice.CardDeviceType, index uint64, volumePercentage float64, ctx context.Context) {
-	_, span := app.SpanWithContext(ctx, "SetVolume")
+// FetchCards returns the cards, along with whether they could be fetched at all.
+func FetchCards(ctx context.Context) ([]*card.Card, bool) {
+	ctx, span := app.SpanWithContext(ctx, "FetchCards")
+	defer span.End()
+
+	ch := make(chan types.CommandResultCards)
+	go fetchCards(ch, ctx)
+
+	result := <-ch
+
+	return result.Cards, result.Success
+}
+
+// FetchCardDevices returns the sources or sinks, along with whether they could be fetched at all.
+func FetchCardDevices(t carddevice.CardDeviceType, ctx context.Context) ([]*carddevice.CardDevice, bool) {
+	ctx, span := app.SpanWithContext(ctx, "FetchCardDevices")
+	defer span.End()
+
+	ch := make(chan types.CommandResultCardDevices)
+	go fetchCardDevices(t, ch, ctx)
+
+	result := <-ch
+
+	return excludeCardDevices(result.CardDevices), result.Success
+}
+
+func SetVolume(t carddevice.CardDeviceType, index uint64, volumePercentage float64, ctx context.Context) error {
+	ctx, span := app.SpanWithContext(ctx, "SetVolume")
 	span.SetAttributes(
 		attribute.Int64("type", int64(t)),
 		attribute.Int64("index", int64(index)),
@@ -69,20 +138,21 @@ func SetVolume(t carddevice.CardDeviceType, index uint64, volumePercentage float
 		arg = "set-sink-volume"
 	}
 
-	volumePercentage = math.Min(volumePercentage, 100)
+	volumePercentage = math.Max(math.Min(volumePercentage, config.Get().Audio.MaxVolume), 0)
 	volume := fmt.Sprint(uint64(math.Round(math.Round((volumePercentage*65535/100)*10) / 10)))
 
-	out, err := exec.Command("pacmd", arg, fmt.Sprint(index), volume).CombinedOutput()
+	if err := pacmdSet(arg, fmt.Sprint(index), volume); err != nil {
+		app.LoggerWithContext(ctx).Errorw("Could not set the volume",
+			"type", t, "index", index, "volume", volumePercentage, "error", err)
 
-	if err != nil {
-		app.Logger.Errorf("Could not set the volume of %v index %v to %v", t, index, volumePercentage)
+		return fmt.Errorf("could not set the volume: %v", err)
 	}
 
-	app.Logger.Debugf("pacmd out: %v", string(out))
+	return nil
 }
 
-func ToggleMute(t carddevice.CardDeviceType, index uint64, mute bool, ctx context.Context) {
-	_, span := app.SpanWithContext(ctx, "ToggleMute")
+func ToggleMute(t carddevice.CardDeviceType, index uint64, mute bool, ctx context.Context) error {
+	ctx, span := app.SpanWithContext(ctx, "ToggleMute")
 	span.SetAttributes(
 		attribute.Int64("type", int64(t)),
 		attribute.Int64("index", int64(index)),
@@ -103,17 +173,18 @@ func ToggleMute(t carddevice.CardDeviceType, index uint64, mute bool, ctx contex
 		muteValue = "0"
 	}
 
-	out, err := exec.Command("pacmd", arg, fmt.Sprint(index), muteValue).CombinedOutput()
+	if err := pacmdSet(arg, fmt.Sprint(index), muteValue); err != nil {
+		app.LoggerWithContext(ctx).Errorw("Could not set the mute status",
+			"type", t, "index", index, "mute", mute, "error", err)
 
-	if err != nil {
-		app.Logger.Errorf("Could not set mute of %v index %v to mute status %v", t, index, muteValue)
+		return fmt.Errorf("could not set the mute status: %v", err)
 	}
 
-	app.Logger.Debugf("pacmd out: %v", string(out))
+	return nil
 }
 
-func SetDefaultCardDevice(t carddevice.CardDeviceType, index uint64, ctx context.Context) {
-	_, span := app.SpanWithContext(ctx, "SetDefaultCardDevice")
+func SetDefaultCardDevice(t carddevice.CardDeviceType, index uint64, ctx context.Context) error {
+	ctx, span := app.SpanWithContext(ctx, "SetDefaultCardDevice")
 	span.SetAttributes(
 		attribute.Int64("type", int64(t)),
 		attribute.Int64("index", int64(index)))
@@ -126,32 +197,39 @@ func SetDefaultCardDevice(t carddevice.CardDeviceType, index uint64, ctx context
 		arg = "set-default-sink"
 	}
 
-	out, err := exec.Command("pacmd", arg, fmt.Sprint(index)).CombinedOutput()
+	if err := pacmdSet(arg, fmt.Sprint(index)); err != nil {
+		app.LoggerWithContext(ctx).Errorw("Could not set the default card device", "type", t, "index", index, "error", err)
 
-	if err != nil {
-		app.Logger.Errorf("Could not set the %v index %v as the default %v", t, index, t)
+		return fmt.Errorf("could not set the default %v: %v", t, err)
 	}
 
-	app.Logger.Debugf("pacmd out: %v", string(out))
+	return nil
 }
 
-func SetCardProfile(index uint64, profile card.CardProfile, ctx context.Context) {
-	_, span := app.SpanWithContext(ctx, "SetCardProfile")
+func SetCardProfile(index uint64, profile card.CardProfile, ctx context.Context) error {
+	ctx, span := app.SpanWithContext(ctx, "SetCardProfile")
 	span.SetAttributes(
 		attribute.Int64("index", int64(index)),
-		attribute.Int64("profile", int64(index)))
+		attribute.String("profile", profile.String()))
 	defer span.End()
 
-	out, err := exec.Command("pacmd", "set-card-profile", fmt.Sprint(index), fmt.Sprint(profile)).CombinedOutput()
+	if err := pacmdSet("set-card-profile", fmt.Sprint(index), fmt.Sprint(profile)); err != nil {
+		app.LoggerWithContext(ctx).Errorw("Could not set the card profile",
+			"card_index", index, "profile", profile, "error", err)
 
-	if err != nil {
-		app.Logger.Errorf("Could not set the card index %v to profile %v", index, profile)
+		return fmt.Errorf("could not set the card profile: %v", err)
 	}
 
-	app.Logger.Debugf("pacmd out: %v", string(out))
+	return nil
 }
 
-func MoveAudioClients(t carddevice.CardDeviceType, index uint64, name string, ctx context.Context) {
+// MoveAudioClients moves every audio client which is not connected to the given card device to it and returns
+// the moved audio clients, as they were before the move.
+func MoveAudioClients(
+	t carddevice.CardDeviceType,
+	index uint64,
+	name string,
+	ctx context.Context) []*audioclient.AudioClient {
 	ctx, span := app.SpanWithContext(ctx, "MoveAudioClients")
 	span.SetAttributes(
 		attribute.Int64("type", int64(t)),
@@ -160,22 +238,34 @@ func MoveAudioClients(t carddevice.CardDeviceType, index uint64, name string, ct
 	defer span.End()
 
 	audioClients := fetchAudioClients(t, ctx)
+	movedAudioClients := []*audioclient.AudioClient{}
 
 	for _, audioClient := range audioClients {
+		if matchesAny(audioClient.Name, config.Get().Exclusions.AudioClients) {
+			continue
+		}
+
 		if audioClient.CardDeviceIndex != index {
-			connectAudioClientToCardDevice(*audioClient, t, name, ctx)
+			if !connectAudioClientToCardDevice(*audioClient, t, name, ctx) {
+				continue
+			}
+
+			movedAudioClients = append(movedAudioClients, audioClient)
 
-			app.Logger.Infof("Moved audio client index %v to default %v %v",
-				audioClient.Index, t, name)
+			app.LoggerWithContext(ctx).Infow("Moved audio client to the default card device",
+				"client_index", audioClient.Index, "client_name", audioClient.Name,
+				"type", t, "index", index, "name", name)
 		}
 	}
+
+	return movedAudioClients
 }
 
 func fetchCards(ch chan<- types.CommandResultCards, ctx context.Context) {
 	_, span := app.SpanWithContext(ctx, "fetchCards")
 	defer span.End()
 
-	out, err := exec.Command("pacmd", "list-cards").CombinedOutput()
+	out, err := pacmd("list-cards")
 
 	ch <- types.CommandResultCards{
 		Success: err == nil,
@@ -193,15 +283,16 @@ func fetchCardDevices(t carddevice.CardDeviceType, ch chan<- types.CommandResult
 		arg = "list-sinks"
 	}
 
-	out, err := exec.Command("pacmd", arg).CombinedOutput()
+	out, err := pacmd(arg)
 
 	ch <- types.CommandResultCardDevices{
 		Success:     err == nil,
 		CardDevices: carddevice.Parse(string(out), ctx)}
 }
 
-func fetchAudioClients(t carddevice.CardDeviceType, ctx context.Context) []*audioclient.AudioClient {
-	ctx, span := app.SpanWithContext(ctx, "fetchClientIndexes")
+// FetchAudioClients returns the sink inputs or source outputs, along with whether they could be fetched at all.
+func FetchAudioClients(t carddevice.CardDeviceType, ctx context.Context) ([]*audioclient.AudioClient, bool) {
+	ctx, span := app.SpanWithContext(ctx, "FetchAudioClients")
 	defer span.End()
 
 	var arg string
@@ -211,20 +302,29 @@ func fetchAudioClients(t carddevice.CardDeviceType, ctx context.Context) []*audi
 		arg = "list-sink-inputs"
 	}
 
-	out, err := exec.Command("pacmd", arg).CombinedOutput()
-	if err != nil {
-		app.Logger.Fatalf("Failed to get the audio client indexes\n")
+	out, err := pacmd(arg)
+
+	return audioclient.Parse(string(out), ctx), err == nil
+}
+
+func fetchAudioClients(t carddevice.CardDeviceType, ctx context.Context) []*audioclient.AudioClient {
+	ctx, span := app.SpanWithContext(ctx, "fetchClientIndexes")
+	defer span.End()
+
+	audioClients, ok := FetchAudioClients(t, ctx)
+	if !ok {
+		app.LoggerWithContext(ctx).Fatalw("Failed to get the audio client indexes", "type", t)
 	}
 
-	return audioclient.Parse(string(out), ctx)
+	return audioClients
 }
 
 func connectAudioClientToCardDevice(
 	audioClient audioclient.AudioClient,
 	t carddevice.CardDeviceType,
 	cardDeviceName string,
-	ctx context.Context) {
-	_, span := app.SpanWithContext(ctx, "connectAudioClientToCardDevice")
+	ctx context.Context) bool {
+	ctx, span := app.SpanWithContext(ctx, "connectAudioClientToCardDevice")
 	defer span.End()
 
 	var arg string
@@ -234,8 +334,58 @@ func connectAudioClientToCardDevice(
 		arg = "move-sink-input"
 	}
 
-	_, err := exec.Command("pacmd", arg, fmt.Sprint(audioClient.Index), cardDeviceName).CombinedOutput()
+	_, err := pacmd(arg, fmt.Sprint(audioClient.Index), cardDeviceName)
 	if err != nil {
-		app.Logger.Errorf("Could not set client index %v to %v %v", audioClient.Index, t, cardDeviceName)
+		app.LoggerWithContext(ctx).Errorw("Could not move audio client",
+			"client_index", audioClient.Index, "type", t, "name", cardDeviceName, "error", err)
+
+		return false
+	}
+
+	return true
+}
+
+func excludeCardDevices(cardDevices []*carddevice.CardDevice) []*carddevice.CardDevice {
+	exclusions := config.Get().Exclusions.CardDevices
+	if len(exclusions) == 0 {
+		return cardDevices
+	}
+
+	includedCardDevices := []*carddevice.CardDevice{}
+	for _, cardDevice := range cardDevices {
+		if !matchesAny(cardDevice.Name, exclusions) {
+			includedCardDevices = append(includedCardDevices, cardDevice)
+		}
+	}
+
+	return includedCardDevices
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inheritBatteryLevels gives the sources and sinks the battery level of their card, which is where the sound server
+// reports it, unless they report one of their own.
+func inheritBatteryLevels(cardsWithDevices *CardsWithDevices) {
+	batteryLevels := make(map[uint64]*uint64)
+	for _, card := range cardsWithDevices.Cards {
+		if card.BatteryLevel != nil {
+			batteryLevels[card.Index] = card.BatteryLevel
+		}
+	}
+
+	for _, cardDevices := range [][]*carddevice.CardDevice{cardsWithDevices.Sources, cardsWithDevices.Sinks} {
+		for _, cardDevice := range cardDevices {
+			if cardDevice.BatteryLevel == nil {
+				cardDevice.BatteryLevel = batteryLevels[cardDevice.CardIndex]
+			}
+		}
 	}
 }
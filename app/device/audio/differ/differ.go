@@ -0,0 +1,193 @@
+package differ
+
+import (
+	"sync"
+
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+)
+
+var started = false
+var singletonLock sync.Mutex
+
+// Start compares each device state snapshot with the previous one and publishes the differences as
+// granular device events.
+func Start(bus *pubsub.Bus) {
+	defer func() { app.Logger.Fatalf("Audio differ has stopped\n") }()
+
+	doStart := func() bool {
+		singletonLock.Lock()
+		defer singletonLock.Unlock()
+		if started {
+			return false
+		} else {
+			started = true
+			return true
+		}
+	}()
+
+	if !doStart {
+		return
+	}
+
+	subscription := event.DeviceStateTopic.Subscribe(bus, "differ", pubsub.Block, 1)
+	defer subscription.Close()
+
+	previous := (*audio.CardsWithDevices)(nil)
+
+	for msg := range subscription.C() {
+		if payload, ok := event.DeviceStateTopic.Payload(msg); ok {
+			func() {
+				ctx, span := app.SpanWithContext(msg.Context(), "Differ Iteration")
+				defer span.End()
+
+				for _, msg := range Diff(previous, payload) {
+					bus.Publish(msg.WithContext(ctx))
+				}
+
+				previous = keepFetched(previous, payload)
+			}()
+		}
+	}
+}
+
+// Diff returns the device events that lead from the previous to the current snapshot.
+//
+// A nil previous snapshot is treated as empty, so that every device is reported as added. The sections of either
+// snapshot which could not be fetched are skipped, rather than reporting every device in them as removed or added.
+func Diff(previous, current *audio.CardsWithDevices) []pubsub.Message {
+	if previous == nil {
+		previous = emptyState()
+	}
+
+	msgs := []pubsub.Message{}
+
+	if previous.Fetched.Sources && current.Fetched.Sources {
+		msgs = append(msgs, diffCardDevices(carddevice.Source, previous.Sources, current.Sources)...)
+	}
+
+	if previous.Fetched.Sinks && current.Fetched.Sinks {
+		msgs = append(msgs, diffCardDevices(carddevice.Sink, previous.Sinks, current.Sinks)...)
+	}
+
+	if previous.Fetched.Cards && current.Fetched.Cards {
+		msgs = append(msgs, diffCards(previous.Cards, current.Cards)...)
+	}
+
+	return msgs
+}
+
+// keepFetched is audio.KeepFetched, with a nil previous snapshot treated as empty, like in Diff, so that the
+// sections which could not be fetched into the first snapshot are reported as added once they are.
+func keepFetched(previous, current *audio.CardsWithDevices) *audio.CardsWithDevices {
+	if previous == nil {
+		previous = emptyState()
+	}
+
+	return audio.KeepFetched(previous, current)
+}
+
+// emptyState is a snapshot with no cards or devices, which stands for the state before the first snapshot.
+func emptyState() *audio.CardsWithDevices {
+	return &audio.CardsWithDevices{Fetched: audio.Fetched{Cards: true, Sources: true, Sinks: true}}
+}
+
+func diffCardDevices(
+	t carddevice.CardDeviceType,
+	previous []*carddevice.CardDevice,
+	current []*carddevice.CardDevice) []pubsub.Message {
+	msgs := []pubsub.Message{}
+
+	previousByIndex := make(map[uint64]*carddevice.CardDevice)
+	previousDefault := (*carddevice.CardDevice)(nil)
+	for _, cardDevice := range previous {
+		previousByIndex[cardDevice.Index] = cardDevice
+
+		if cardDevice.IsDefault {
+			previousDefault = cardDevice
+		}
+	}
+
+	currentByIndex := make(map[uint64]*carddevice.CardDevice)
+	currentDefault := (*carddevice.CardDevice)(nil)
+	for _, cardDevice := range current {
+		currentByIndex[cardDevice.Index] = cardDevice
+
+		if cardDevice.IsDefault {
+			currentDefault = cardDevice
+		}
+
+		previousCardDevice, ok := previousByIndex[cardDevice.Index]
+		if !ok {
+			msgs = append(msgs, event.DeviceAddedTopic.Message(&event.DeviceAdded{
+				Type:   t,
+				Device: cardDevice,
+			}))
+
+			continue
+		}
+
+		if cardDevice.Volume != previousCardDevice.Volume {
+			msgs = append(msgs, event.VolumeChangedTopic.Message(&event.VolumeChanged{
+				Type:           t,
+				Device:         cardDevice,
+				PreviousVolume: previousCardDevice.Volume,
+			}))
+		}
+
+		if cardDevice.IsMuted != previousCardDevice.IsMuted {
+			msgs = append(msgs, event.MuteChangedTopic.Message(&event.MuteChanged{
+				Type:   t,
+				Device: cardDevice,
+			}))
+		}
+	}
+
+	for _, cardDevice := range previous {
+		if _, ok := currentByIndex[cardDevice.Index]; !ok {
+			msgs = append(msgs, event.DeviceRemovedTopic.Message(&event.DeviceRemoved{
+				Type:   t,
+				Device: cardDevice,
+			}))
+		}
+	}
+
+	if currentDefault != nil && (previousDefault == nil || currentDefault.Index != previousDefault.Index) {
+		msgs = append(msgs, event.DefaultChangedTopic.Message(&event.DefaultChanged{
+			Type:           t,
+			Device:         currentDefault,
+			PreviousDevice: previousDefault,
+		}))
+	}
+
+	return msgs
+}
+
+func diffCards(previous []*card.Card, current []*card.Card) []pubsub.Message {
+	msgs := []pubsub.Message{}
+
+	previousByIndex := make(map[uint64]*card.Card)
+	for _, c := range previous {
+		previousByIndex[c.Index] = c
+	}
+
+	for _, c := range current {
+		previousCard, ok := previousByIndex[c.Index]
+		if !ok {
+			continue
+		}
+
+		if c.ActiveProfile != previousCard.ActiveProfile {
+			msgs = append(msgs, event.ProfileChangedTopic.Message(&event.ProfileChanged{
+				Card:            c,
+				PreviousProfile: previousCard.ActiveProfile,
+			}))
+		}
+	}
+
+	return msgs
+}
@@ -0,0 +1,173 @@
+package differ
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+)
+
+// snapshot builds a device state with one card, one source and one sink, leaving out the sections named in failed,
+// as when they could not be fetched.
+func snapshot(volume float64, muted bool, profile card.CardProfile, failed ...string) *audio.CardsWithDevices {
+	state := &audio.CardsWithDevices{
+		Cards:   []*card.Card{{Index: 1, ActiveProfile: profile}},
+		Sources: []*carddevice.CardDevice{{Index: 2, IsDefault: true, Volume: 30}},
+		Sinks:   []*carddevice.CardDevice{{Index: 3, IsDefault: true, Volume: volume, IsMuted: muted}},
+		Fetched: audio.Fetched{Cards: true, Sources: true, Sinks: true},
+	}
+
+	for _, section := range failed {
+		switch section {
+		case "cards":
+			state.Cards, state.Fetched.Cards = nil, false
+		case "sources":
+			state.Sources, state.Fetched.Sources = nil, false
+		case "sinks":
+			state.Sinks, state.Fetched.Sinks = nil, false
+		}
+	}
+
+	return state
+}
+
+// describe names each message by its topic and the device or card it is about, in sorted order.
+func describe(msgs []pubsub.Message) []string {
+	descriptions := []string{}
+	for _, msg := range msgs {
+		about := ""
+		switch payload := msg.Payload().(type) {
+		case *event.DeviceAdded:
+			about = fmt.Sprintf("%v %v", payload.Type, payload.Device.Index)
+		case *event.DeviceRemoved:
+			about = fmt.Sprintf("%v %v", payload.Type, payload.Device.Index)
+		case *event.VolumeChanged:
+			about = fmt.Sprintf("%v %v", payload.Type, payload.Device.Index)
+		case *event.MuteChanged:
+			about = fmt.Sprintf("%v %v", payload.Type, payload.Device.Index)
+		case *event.DefaultChanged:
+			about = fmt.Sprintf("%v %v", payload.Type, payload.Device.Index)
+		case *event.ProfileChanged:
+			about = fmt.Sprintf("card %v", payload.Card.Index)
+		}
+
+		descriptions = append(descriptions, fmt.Sprintf("%v %v", msg.Topic, about))
+	}
+
+	sort.Strings(descriptions)
+
+	return descriptions
+}
+
+func TestDiff(t *testing.T) {
+	added := []string{
+		"default_changed sink 3",
+		"default_changed source 2",
+		"device_added sink 3",
+		"device_added source 2",
+	}
+
+	// Each test diffs its snapshots in turn, like Start, and wants the events of each of them.
+	tests := []struct {
+		name      string
+		snapshots []*audio.CardsWithDevices
+		want      [][]string
+	}{
+		{
+			"first snapshot",
+			[]*audio.CardsWithDevices{snapshot(50, false, card.Off)},
+			[][]string{added},
+		},
+		{
+			"no change",
+			[]*audio.CardsWithDevices{snapshot(50, false, card.Off), snapshot(50, false, card.Off)},
+			[][]string{added, {}},
+		},
+		{
+			"changes",
+			[]*audio.CardsWithDevices{snapshot(50, false, card.Off), snapshot(60, true, card.A2DPSinkSBC)},
+			[][]string{added, {"mute_changed sink 3", "profile_changed card 1", "volume_changed sink 3"}},
+		},
+		{
+			"sink removed",
+			[]*audio.CardsWithDevices{
+				snapshot(50, false, card.Off),
+				func() *audio.CardsWithDevices {
+					state := snapshot(50, false, card.Off)
+					state.Sinks = nil
+
+					return state
+				}(),
+			},
+			[][]string{added, {"device_removed sink 3"}},
+		},
+		{
+			"sinks not fetched",
+			[]*audio.CardsWithDevices{snapshot(50, false, card.Off), snapshot(50, false, card.Off, "sinks")},
+			[][]string{added, {}},
+		},
+		{
+			"cards not fetched along with a profile change",
+			[]*audio.CardsWithDevices{
+				snapshot(50, false, card.Off),
+				snapshot(60, false, card.A2DPSinkSBC, "cards"),
+			},
+			[][]string{added, {"volume_changed sink 3"}},
+		},
+		{
+			"sinks fetched again",
+			[]*audio.CardsWithDevices{
+				snapshot(50, false, card.Off),
+				snapshot(60, false, card.Off, "sinks"),
+				snapshot(60, false, card.Off),
+			},
+			[][]string{added, {}, {"volume_changed sink 3"}},
+		},
+		{
+			"sinks fetched again unchanged",
+			[]*audio.CardsWithDevices{
+				snapshot(50, false, card.Off),
+				snapshot(50, false, card.Off, "sinks"),
+				snapshot(50, false, card.Off),
+			},
+			[][]string{added, {}, {}},
+		},
+		{
+			"sinks not fetched into the first snapshot",
+			[]*audio.CardsWithDevices{snapshot(50, false, card.Off, "sinks"), snapshot(50, false, card.Off)},
+			[][]string{
+				{"default_changed source 2", "device_added source 2"},
+				{"default_changed sink 3", "device_added sink 3"},
+			},
+		},
+		{
+			"nothing fetched",
+			[]*audio.CardsWithDevices{
+				snapshot(50, false, card.Off),
+				snapshot(50, false, card.Off, "cards", "sources", "sinks"),
+				snapshot(50, false, card.Off),
+			},
+			[][]string{added, {}, {}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previous := (*audio.CardsWithDevices)(nil)
+
+			for i, current := range test.snapshots {
+				if got := describe(Diff(previous, current)); !reflect.DeepEqual(got, test.want[i]) {
+					t.Errorf("snapshot %v: got %v, want %v", i, got, test.want[i])
+				}
+
+				previous = keepFetched(previous, current)
+			}
+		})
+	}
+}
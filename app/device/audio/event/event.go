@@ -0,0 +1,61 @@
+package event
+
+import (
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/audioclient"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+)
+
+type DeviceAdded struct {
+	Type   carddevice.CardDeviceType `json:"type"`
+	Device *carddevice.CardDevice    `json:"device"`
+}
+
+type DeviceRemoved struct {
+	Type   carddevice.CardDeviceType `json:"type"`
+	Device *carddevice.CardDevice    `json:"device"`
+}
+
+type VolumeChanged struct {
+	Type           carddevice.CardDeviceType `json:"type"`
+	Device         *carddevice.CardDevice    `json:"device"`
+	PreviousVolume float64                   `json:"previousVolume"`
+}
+
+type MuteChanged struct {
+	Type   carddevice.CardDeviceType `json:"type"`
+	Device *carddevice.CardDevice    `json:"device"`
+}
+
+type DefaultChanged struct {
+	Type           carddevice.CardDeviceType `json:"type"`
+	Device         *carddevice.CardDevice    `json:"device"`
+	PreviousDevice *carddevice.CardDevice    `json:"previousDevice"`
+}
+
+type ProfileChanged struct {
+	Card            *card.Card       `json:"card"`
+	PreviousProfile card.CardProfile `json:"previousProfile"`
+}
+
+type StreamMoved struct {
+	Type                carddevice.CardDeviceType `json:"type"`
+	ClientIndex         uint64                    `json:"clientIndex"`
+	FromCardDeviceIndex uint64                    `json:"fromCardDeviceIndex"`
+	ToCardDeviceIndex   uint64                    `json:"toCardDeviceIndex"`
+	ToCardDeviceName    string                    `json:"toCardDeviceName"`
+}
+
+func NewStreamMoved(
+	t carddevice.CardDeviceType,
+	audioClient *audioclient.AudioClient,
+	toCardDeviceIndex uint64,
+	toCardDeviceName string) *StreamMoved {
+	return &StreamMoved{
+		Type:                t,
+		ClientIndex:         audioClient.Index,
+		FromCardDeviceIndex: audioClient.CardDeviceIndex,
+		ToCardDeviceIndex:   toCardDeviceIndex,
+		ToCardDeviceName:    toCardDeviceName,
+	}
+}
@@ -0,0 +1,18 @@
+package event
+
+import (
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+)
+
+// The topics of the event bus, along with the types of their payloads.
+var (
+	DeviceStateTopic    = pubsub.NewTypedTopic[*audio.CardsWithDevices](pubsub.TopicDeviceState)
+	DeviceAddedTopic    = pubsub.NewTypedTopic[*DeviceAdded](pubsub.TopicDeviceAdded)
+	DeviceRemovedTopic  = pubsub.NewTypedTopic[*DeviceRemoved](pubsub.TopicDeviceRemoved)
+	VolumeChangedTopic  = pubsub.NewTypedTopic[*VolumeChanged](pubsub.TopicVolumeChanged)
+	MuteChangedTopic    = pubsub.NewTypedTopic[*MuteChanged](pubsub.TopicMuteChanged)
+	DefaultChangedTopic = pubsub.NewTypedTopic[*DefaultChanged](pubsub.TopicDefaultChanged)
+	ProfileChangedTopic = pubsub.NewTypedTopic[*ProfileChanged](pubsub.TopicProfileChanged)
+	StreamMovedTopic    = pubsub.NewTypedTopic[*StreamMoved](pubsub.TopicStreamMoved)
+)
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/device/bus"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+)
+
+var (
+	volumeDesc = prometheus.NewDesc(
+		"cctl_device_volume_percent",
+		"Volume percentage of a source or sink.",
+		[]string{"type", "name"}, nil)
+	mutedDesc = prometheus.NewDesc(
+		"cctl_device_muted",
+		"Whether a source or sink is muted.",
+		[]string{"type", "name"}, nil)
+	defaultDesc = prometheus.NewDesc(
+		"cctl_device_default",
+		"Whether a source or sink is the default one.",
+		[]string{"type", "name"}, nil)
+	devicesDesc = prometheus.NewDesc(
+		"cctl_devices",
+		"Number of sources or sinks by bus and form factor.",
+		[]string{"type", "bus", "form_factor"}, nil)
+	bluetoothProfileDesc = prometheus.NewDesc(
+		"cctl_bluetooth_card_profile",
+		"The active profile of a Bluetooth card, which is always 1.",
+		[]string{"card", "profile"}, nil)
+	bluetoothCodecDesc = prometheus.NewDesc(
+		"cctl_bluetooth_codec",
+		"The A2DP codec of a Bluetooth source or sink, which is always 1.",
+		[]string{"type", "name", "codec"}, nil)
+	bluetoothBatteryDesc = prometheus.NewDesc(
+		"cctl_bluetooth_battery_percent",
+		"Battery percentage of a Bluetooth source or sink, when the sound server reports it.",
+		[]string{"type", "name"}, nil)
+)
+
+// collector exposes the latest device state as prometheus metrics, which are computed at scrape time.
+type collector struct {
+	lock  sync.RWMutex
+	state *audio.CardsWithDevices
+}
+
+var started = false
+var singletonLock sync.Mutex
+
+// Start keeps the audio metrics up to date with the device state.
+func Start(eventBus *pubsub.Bus) {
+	defer func() { app.Logger.Fatalf("Audio metrics have stopped\n") }()
+
+	doStart := func() bool {
+		singletonLock.Lock()
+		defer singletonLock.Unlock()
+		if started {
+			return false
+		} else {
+			started = true
+			return true
+		}
+	}()
+
+	if !doStart {
+		return
+	}
+
+	c := &collector{state: new(audio.CardsWithDevices)}
+	prometheus.MustRegister(c)
+
+	subscription := event.DeviceStateTopic.Subscribe(eventBus, "metrics", pubsub.DropOldest, 1)
+	defer subscription.Close()
+
+	for msg := range subscription.C() {
+		if payload, ok := event.DeviceStateTopic.Payload(msg); ok {
+			c.lock.Lock()
+			c.state = payload
+			c.lock.Unlock()
+		}
+	}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- volumeDesc
+	ch <- mutedDesc
+	ch <- defaultDesc
+	ch <- devicesDesc
+	ch <- bluetoothProfileDesc
+	ch <- bluetoothCodecDesc
+	ch <- bluetoothBatteryDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	state := c.state
+	c.lock.RUnlock()
+
+	collectCardDevices(ch, carddevice.Source, state.Sources)
+	collectCardDevices(ch, carddevice.Sink, state.Sinks)
+
+	for _, card := range state.Cards {
+		if card.Bus != bus.Bluetooth || card.ActiveProfile == 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			bluetoothProfileDesc, prometheus.GaugeValue, 1, card.Name, card.ActiveProfile.String())
+	}
+}
+
+func collectCardDevices(
+	ch chan<- prometheus.Metric,
+	t carddevice.CardDeviceType,
+	cardDevices []*carddevice.CardDevice) {
+	type deviceKind struct {
+		bus        string
+		formFactor string
+	}
+
+	kinds := make(map[deviceKind]int)
+
+	for _, cardDevice := range cardDevices {
+		ch <- prometheus.MustNewConstMetric(
+			volumeDesc, prometheus.GaugeValue, cardDevice.Volume, t.String(), cardDevice.Name)
+		ch <- prometheus.MustNewConstMetric(
+			mutedDesc, prometheus.GaugeValue, boolValue(cardDevice.IsMuted), t.String(), cardDevice.Name)
+		ch <- prometheus.MustNewConstMetric(
+			defaultDesc, prometheus.GaugeValue, boolValue(cardDevice.IsDefault), t.String(), cardDevice.Name)
+
+		if cardDevice.Bus == bus.Bluetooth {
+			ch <- prometheus.MustNewConstMetric(
+				bluetoothCodecDesc, prometheus.GaugeValue, 1, t.String(), cardDevice.Name, cardDevice.A2DPCodec.String())
+		}
+
+		if cardDevice.BatteryLevel != nil {
+			ch <- prometheus.MustNewConstMetric(
+				bluetoothBatteryDesc, prometheus.GaugeValue, float64(*cardDevice.BatteryLevel), t.String(), cardDevice.Name)
+		}
+
+		kinds[deviceKind{bus: cardDevice.Bus.String(), formFactor: cardDevice.FormFactor.String()}]++
+	}
+
+	for kind, count := range kinds {
+		ch <- prometheus.MustNewConstMetric(
+			devicesDesc, prometheus.GaugeValue, float64(count), t.String(), kind.bus, kind.formFactor)
+	}
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
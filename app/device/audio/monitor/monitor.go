@@ -5,14 +5,16 @@ import (
 	"time"
 
 	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/config"
 	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
 	"github.com/sadesyllas/go-cctl/app/pubsub"
 )
 
 var started = false
 var singletonLock sync.Mutex
 
-func Start() {
+func Start(bus *pubsub.Bus) {
 	defer func() { app.Logger.Fatalf("Audio monitor has stopped\n") }()
 
 	doStart := func() bool {
@@ -31,8 +33,13 @@ func Start() {
 	}
 
 	for {
-		pubsub.Send(pubsub.NewMessage(pubsub.TopicDeviceState, audio.FetchCardsWithDevices()))
+		func() {
+			ctx, span := app.Span("Monitor Iteration")
+			defer span.End()
 
-		time.Sleep(15 * time.Second)
+			event.DeviceStateTopic.Publish(bus, audio.FetchCardsWithDevices(ctx), ctx)
+		}()
+
+		time.Sleep(config.Get().Monitor.PollInterval)
 	}
 }
@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var pacmdDur = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "cctl_pacmd_duration_seconds",
+	Help:    "Duration of pacmd invocations by subcommand.",
+	Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms to ~8s
+},
+	[]string{"subcommand"},
+)
+
+var pacmdFailureCnt = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cctl_pacmd_failures_total",
+		Help: "Total number of failed pacmd invocations by subcommand.",
+	},
+	[]string{"subcommand"},
+)
+
+// pacmd runs pacmd with the given subcommand and arguments and returns its combined output.
+func pacmd(subcommand string, args ...string) ([]byte, error) {
+	start := time.Now()
+
+	out, err := exec.Command("pacmd", append([]string{subcommand}, args...)...).CombinedOutput()
+
+	pacmdDur.WithLabelValues(subcommand).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		pacmdFailureCnt.WithLabelValues(subcommand).Inc()
+	}
+
+	return out, err
+}
+
+// pacmdSet runs a pacmd subcommand which changes the device state, which prints nothing unless it fails, e.g. with
+// "No sink found by this name or index." for a sink which is gone, while still exiting successfully.
+func pacmdSet(subcommand string, args ...string) error {
+	out, err := pacmd(subcommand, args...)
+	message := strings.TrimSpace(string(out))
+
+	if err != nil {
+		if message == "" {
+			return err
+		}
+
+		return fmt.Errorf("%v: %v", err, message)
+	}
+
+	if message != "" {
+		pacmdFailureCnt.WithLabelValues(subcommand).Inc()
+
+		return errors.New(message)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package audio
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+)
+
+const (
+	DefaultAlias       = "@default"
+	DefaultSinkAlias   = "@DEFAULT_SINK@"
+	DefaultSourceAlias = "@DEFAULT_SOURCE@"
+)
+
+// FindCardDevice returns the source or sink identified by id, which is tried, in order, as:
+//
+//   - an alias of the default card device, i.e. "@default", "@DEFAULT_SINK@" or "@DEFAULT_SOURCE@"
+//   - an index
+//   - a name, e.g. "bluez_sink.XX_XX_XX_XX_XX_XX.a2dp_sink"
+//   - a case insensitive shell pattern matching exactly one description, e.g. "*headphones*"
+func (cardsWithDevices *CardsWithDevices) FindCardDevice(
+	t carddevice.CardDeviceType,
+	id string) (*carddevice.CardDevice, error) {
+	cardDevices := cardsWithDevices.Sinks
+	defaultAlias := DefaultSinkAlias
+	if t == carddevice.Source {
+		cardDevices = cardsWithDevices.Sources
+		defaultAlias = DefaultSourceAlias
+	}
+
+	if id == DefaultAlias || id == defaultAlias {
+		for _, cardDevice := range cardDevices {
+			if cardDevice.IsDefault {
+				return cardDevice, nil
+			}
+		}
+
+		return nil, fmt.Errorf("no default %v", t)
+	}
+
+	if index, err := strconv.ParseUint(id, 10, 64); err == nil {
+		for _, cardDevice := range cardDevices {
+			if cardDevice.Index == index {
+				return cardDevice, nil
+			}
+		}
+	}
+
+	for _, cardDevice := range cardDevices {
+		if cardDevice.Name == id {
+			return cardDevice, nil
+		}
+	}
+
+	descriptions := make([]string, len(cardDevices))
+	for i, cardDevice := range cardDevices {
+		descriptions[i] = cardDevice.Description
+	}
+
+	i, err := matchDescription(id, descriptions)
+	if err != nil {
+		return nil, fmt.Errorf("%v %v: %v", t, id, err)
+	}
+
+	return cardDevices[i], nil
+}
+
+// FindCard returns the card identified by id, which is tried, in order, as an index, a name or a description
+// pattern, like in FindCardDevice.
+func (cardsWithDevices *CardsWithDevices) FindCard(id string) (*card.Card, error) {
+	if index, err := strconv.ParseUint(id, 10, 64); err == nil {
+		for _, c := range cardsWithDevices.Cards {
+			if c.Index == index {
+				return c, nil
+			}
+		}
+	}
+
+	for _, c := range cardsWithDevices.Cards {
+		if c.Name == id {
+			return c, nil
+		}
+	}
+
+	descriptions := make([]string, len(cardsWithDevices.Cards))
+	for i, c := range cardsWithDevices.Cards {
+		descriptions[i] = c.Description
+	}
+
+	i, err := matchDescription(id, descriptions)
+	if err != nil {
+		return nil, fmt.Errorf("card %v: %v", id, err)
+	}
+
+	return cardsWithDevices.Cards[i], nil
+}
+
+func matchDescription(pattern string, descriptions []string) (int, error) {
+	pattern = strings.ToLower(pattern)
+	found := -1
+
+	for i, description := range descriptions {
+		matched, err := path.Match(pattern, strings.ToLower(description))
+		if err != nil {
+			return -1, fmt.Errorf("invalid description pattern")
+		}
+
+		if !matched {
+			continue
+		}
+
+		if found >= 0 {
+			return -1, fmt.Errorf("ambiguous description pattern")
+		}
+
+		found = i
+	}
+
+	if found < 0 {
+		return -1, fmt.Errorf("not found")
+	}
+
+	return found, nil
+}
@@ -0,0 +1,108 @@
+package scene
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+)
+
+// ruleCooldown is how long a rule is left alone after it has been applied, since the scene it applies sets off
+// events in turn, which rules whose scenes conflict would otherwise keep answering one another with.
+const ruleCooldown = 10 * time.Second
+
+var started = false
+var singletonLock sync.Mutex
+
+// Start applies the scenes of the rules of the configuration, as it is when each event arrives, to the events
+// which they match.
+func Start(bus *pubsub.Bus) {
+	defer func() { app.Logger.Fatalf("Scene rules have stopped\n") }()
+
+	doStart := func() bool {
+		singletonLock.Lock()
+		defer singletonLock.Unlock()
+		if started {
+			return false
+		} else {
+			started = true
+			return true
+		}
+	}()
+
+	if !doStart {
+		return
+	}
+
+	subscription := bus.Register("rules", pubsub.DropOldest, 8,
+		pubsub.TopicDeviceAdded, pubsub.TopicDeviceRemoved, pubsub.TopicDefaultChanged)
+	defer subscription.Close()
+
+	lastApplied := make(map[config.RuleConfig]time.Time)
+
+	for msg := range subscription.C() {
+		var t carddevice.CardDeviceType
+		var cardDevice *carddevice.CardDevice
+
+		if payload, ok := event.DeviceAddedTopic.Payload(msg); ok {
+			t, cardDevice = payload.Type, payload.Device
+		} else if payload, ok := event.DeviceRemovedTopic.Payload(msg); ok {
+			t, cardDevice = payload.Type, payload.Device
+		} else if payload, ok := event.DefaultChangedTopic.Payload(msg); ok {
+			t, cardDevice = payload.Type, payload.Device
+		} else {
+			continue
+		}
+
+		for _, ruleConfig := range config.Get().Rules {
+			if !matches(ruleConfig, msg.Topic, t, cardDevice) {
+				continue
+			}
+
+			if appliedAt, ok := lastApplied[ruleConfig]; ok && time.Since(appliedAt) < ruleCooldown {
+				app.LoggerWithContext(msg.Context()).Warnw("Skipped a rule which has just been applied",
+					"scene", ruleConfig.Scene, "on", ruleConfig.On, "type", t, "name", cardDevice.Name)
+
+				continue
+			}
+
+			lastApplied[ruleConfig] = time.Now()
+
+			func() {
+				ctx, span := app.SpanWithContext(msg.Context(), "Rule Iteration")
+				defer span.End()
+
+				app.LoggerWithContext(ctx).Infow("Applying the scene of a rule",
+					"scene", ruleConfig.Scene, "on", ruleConfig.On, "type", t, "name", cardDevice.Name)
+
+				if _, err := Apply(ruleConfig.Scene, bus, ctx); err != nil {
+					app.LoggerWithContext(ctx).Errorw("Could not apply the scene of a rule",
+						"scene", ruleConfig.Scene, "error", err)
+				}
+			}()
+		}
+	}
+}
+
+func matches(
+	ruleConfig config.RuleConfig,
+	topic pubsub.Topic,
+	t carddevice.CardDeviceType,
+	cardDevice *carddevice.CardDevice) bool {
+	if ruleConfig.On != topic.String() || (ruleConfig.Type != "" && ruleConfig.Type != t.String()) {
+		return false
+	}
+
+	if ruleConfig.Name == "" {
+		return true
+	}
+
+	matched, _ := filepath.Match(ruleConfig.Name, cardDevice.Name)
+
+	return matched
+}
@@ -0,0 +1,156 @@
+package scene
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var appliedCnt = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cctl_scenes_applied_total",
+		Help: "Total number of scenes applied, by whether every setting of the scene has been carried out.",
+	},
+	[]string{"scene", "result"},
+)
+
+// Exists reports whether the configuration has a scene with the given name.
+func Exists(name string) bool {
+	_, ok := config.Get().Scenes[name]
+
+	return ok
+}
+
+// Apply brings the devices to the scene of the configuration with the given name and returns the device state it
+// has left them in.
+//
+// The settings of cards and card devices which are not present are skipped, while those which pacmd fails to carry
+// out are reported in the error, after the rest have been carried out. The streams moved to the default card
+// devices and the resulting device state are published on bus, unless it is nil, e.g. in the command line client.
+func Apply(name string, bus *pubsub.Bus, ctx context.Context) (*audio.CardsWithDevices, error) {
+	ctx, span := app.SpanWithContext(ctx, "Apply Scene")
+	span.SetAttributes(attribute.String("scene", name))
+	defer span.End()
+
+	sceneConfig, ok := config.Get().Scenes[name]
+	if !ok {
+		return nil, fmt.Errorf("no such scene: %v", name)
+	}
+
+	logger := app.LoggerWithContext(ctx).With("scene", name)
+	errs := []string{}
+
+	cardsWithDevices := audio.FetchCardsWithDevices(ctx)
+
+	// The profiles go first, since they decide which card devices the cards have.
+	for _, profileConfig := range sceneConfig.Profiles {
+		c, err := cardsWithDevices.FindCard(profileConfig.Card)
+		if err != nil {
+			logger.Infow("Skipped the profile of a card which is not present", "card", profileConfig.Card, "error", err)
+
+			continue
+		}
+
+		profile, _ := card.ParseableProfile(profileConfig.Profile).Parse()
+		if c.ActiveProfile == profile {
+			continue
+		}
+
+		if err := audio.SetCardProfile(c.Index, profile, ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(sceneConfig.Profiles) != 0 {
+		cardsWithDevices = audio.FetchCardsWithDevices(ctx)
+	}
+
+	defaults := map[carddevice.CardDeviceType]string{
+		carddevice.Source: sceneConfig.DefaultSource,
+		carddevice.Sink:   sceneConfig.DefaultSink,
+	}
+
+	for _, t := range []carddevice.CardDeviceType{carddevice.Source, carddevice.Sink} {
+		if defaults[t] == "" {
+			continue
+		}
+
+		cardDevice, err := cardsWithDevices.FindCardDevice(t, defaults[t])
+		if err != nil {
+			logger.Infow("Skipped the default card device, which is not present", "type", t, "id", defaults[t],
+				"error", err)
+
+			continue
+		}
+
+		if err := audio.SetDefaultCardDevice(t, cardDevice.Index, ctx); err != nil {
+			errs = append(errs, err.Error())
+
+			continue
+		}
+
+		for _, audioClient := range audio.MoveAudioClients(t, cardDevice.Index, cardDevice.Name, ctx) {
+			if bus != nil {
+				event.StreamMovedTopic.Publish(bus,
+					event.NewStreamMoved(t, audioClient, cardDevice.Index, cardDevice.Name), ctx)
+			}
+		}
+	}
+
+	// The devices may refer to the new default card devices as @default.
+	if sceneConfig.DefaultSource != "" || sceneConfig.DefaultSink != "" {
+		cardsWithDevices = audio.FetchCardsWithDevices(ctx)
+	}
+
+	for _, deviceConfig := range sceneConfig.Devices {
+		t, _ := carddevice.ParseableCardDeviceType(deviceConfig.Type).Parse()
+
+		cardDevice, err := cardsWithDevices.FindCardDevice(t, deviceConfig.ID)
+		if err != nil {
+			logger.Infow("Skipped a card device which is not present", "type", t, "id", deviceConfig.ID, "error", err)
+
+			continue
+		}
+
+		if deviceConfig.Volume != nil {
+			if err := audio.SetVolume(t, cardDevice.Index, *deviceConfig.Volume, ctx); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		if deviceConfig.Muted != nil {
+			if err := audio.ToggleMute(t, cardDevice.Index, *deviceConfig.Muted, ctx); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	cardsWithDevices = audio.FetchCardsWithDevices(ctx)
+
+	if bus != nil {
+		event.DeviceStateTopic.Publish(bus, cardsWithDevices, ctx)
+	}
+
+	if len(errs) != 0 {
+		appliedCnt.WithLabelValues(name, "failed").Inc()
+
+		return cardsWithDevices, fmt.Errorf("could not apply the scene %v: %v", name, strings.Join(errs, "; "))
+	}
+
+	appliedCnt.WithLabelValues(name, "ok").Inc()
+
+	logger.Infow("Applied the scene")
+
+	return cardsWithDevices, nil
+}
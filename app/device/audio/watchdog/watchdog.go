@@ -1,18 +1,30 @@
 package watchdog
 
 import (
+	"context"
 	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sadesyllas/go-cctl/app"
 	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
 	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
 	"github.com/sadesyllas/go-cctl/app/pubsub"
 )
 
+var movedCnt = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cctl_watchdog_moved_streams_total",
+		Help: "Total number of audio clients moved to the default card device by the watchdog.",
+	},
+	[]string{"type"},
+)
+
 var started = false
 var singletonLock sync.Mutex
 
-func Start() {
+func Start(bus *pubsub.Bus) {
 	defer func() { app.Logger.Fatalf("Audio watchdog has stopped\n") }()
 
 	doStart := func() bool {
@@ -30,14 +42,13 @@ func Start() {
 		return
 	}
 
-	inbound := make(chan pubsub.Message)
+	subscription := event.DeviceStateTopic.Subscribe(bus, "watchdog", pubsub.DropOldest, 1)
+	defer subscription.Close()
 
-	pubsub.Register(pubsub.TopicDeviceState, inbound)
-
-	for msg := range inbound {
-		if payload, ok := msg.Payload.(*audio.CardsWithDevices); ok {
+	for msg := range subscription.C() {
+		if payload, ok := event.DeviceStateTopic.Payload(msg); ok {
 			func() {
-				ctx, span := app.Span("Watchdog Iteration")
+				ctx, span := app.SpanWithContext(msg.Context(), "Watchdog Iteration")
 				defer span.End()
 
 				defaultSource, defaultSink := (*carddevice.CardDevice)(nil), (*carddevice.CardDevice)(nil)
@@ -55,13 +66,25 @@ func Start() {
 				}
 
 				if defaultSource != nil {
-					audio.MoveAudioClients(carddevice.Source, defaultSource.Index, defaultSource.Name, ctx)
+					moveAudioClients(bus, carddevice.Source, defaultSource, ctx)
 				}
 
 				if defaultSink != nil {
-					audio.MoveAudioClients(carddevice.Sink, defaultSink.Index, defaultSink.Name, ctx)
+					moveAudioClients(bus, carddevice.Sink, defaultSink, ctx)
 				}
 			}()
 		}
 	}
 }
+
+func moveAudioClients(
+	bus *pubsub.Bus,
+	t carddevice.CardDeviceType,
+	cardDevice *carddevice.CardDevice,
+	ctx context.Context) {
+	for _, audioClient := range audio.MoveAudioClients(t, cardDevice.Index, cardDevice.Name, ctx) {
+		movedCnt.WithLabelValues(t.String()).Inc()
+
+		event.StreamMovedTopic.Publish(bus, event.NewStreamMoved(t, audioClient, cardDevice.Index, cardDevice.Name), ctx)
+	}
+}
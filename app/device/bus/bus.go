@@ -3,35 +3,63 @@ package bus
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/util/enum"
 )
 
 type Bus uint64
 
 const (
-	PCI Bus = iota + 1
+	Unknown Bus = enum.Unknown
+	PCI     Bus = iota
 	Bluetooth
 	USB
+	FireWire
+	ISA
+	HDMI
+	DisplayPort
 )
 
+var names = enum.NewNames(map[uint64]string{
+	uint64(PCI):         "pci",
+	uint64(Bluetooth):   "bluetooth",
+	uint64(USB):         "usb",
+	uint64(FireWire):    "firewire",
+	uint64(ISA):         "isa",
+	uint64(HDMI):        "hdmi",
+	uint64(DisplayPort): "displayport",
+})
+
 type ParseableBus string
 
-func (value ParseableBus) Parse(ctx context.Context) (bus Bus, err error) {
+func (value ParseableBus) Parse(ctx context.Context) (Bus, error) {
 	_, span := app.SpanWithContext(ctx, "Parse device BUS")
 	defer span.End()
 
-	switch strings.ToLower(string(value)) {
-	case "pci":
-		bus = PCI
-	case "bluetooth":
-		bus = Bluetooth
-	case "usb":
-		bus = USB
-	default:
-		err = fmt.Errorf("invalid device bus: %v", value)
+	bus, ok := names.ParseReported(string(value))
+	if !ok {
+		return Bus(bus), fmt.Errorf("invalid device bus: %v", value)
+	}
+
+	return Bus(bus), nil
+}
+
+func (value Bus) String() string {
+	return names.Name(uint64(value))
+}
+
+func (value Bus) MarshalJSON() ([]byte, error) {
+	return names.Encode(uint64(value))
+}
+
+func (value *Bus) UnmarshalJSON(data []byte) error {
+	bus, err := names.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid device bus: %v", err)
 	}
 
-	return
+	*value = Bus(bus)
+
+	return nil
 }
@@ -3,38 +3,75 @@ package formfactor
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/util/enum"
 )
 
 type FormFactor uint64
 
 const (
-	Internal FormFactor = iota + 1
+	Unknown  FormFactor = enum.Unknown
+	Internal FormFactor = iota
 	Headphones
 	Webcam
 	Headset
+	Speaker
+	Microphone
+	Handset
+	HandsFree
+	TV
+	Car
+	HiFi
+	Portable
+	Computer
 )
 
+var names = enum.NewNames(map[uint64]string{
+	uint64(Internal):   "internal",
+	uint64(Headphones): "headphone",
+	uint64(Webcam):     "webcam",
+	uint64(Headset):    "headset",
+	uint64(Speaker):    "speaker",
+	uint64(Microphone): "microphone",
+	uint64(Handset):    "handset",
+	uint64(HandsFree):  "hands-free",
+	uint64(TV):         "tv",
+	uint64(Car):        "car",
+	uint64(HiFi):       "hifi",
+	uint64(Portable):   "portable",
+	uint64(Computer):   "computer",
+})
+
 type ParseableFormFactor string
 
-func (value ParseableFormFactor) Parse(ctx context.Context) (formFactor FormFactor, err error) {
+func (value ParseableFormFactor) Parse(ctx context.Context) (FormFactor, error) {
 	_, span := app.SpanWithContext(ctx, "Parse device form factor")
 	defer span.End()
 
-	switch strings.ToLower(string(value)) {
-	case "internal":
-		formFactor = Internal
-	case "headphone":
-		formFactor = Headphones
-	case "webcam":
-		formFactor = Webcam
-	case "headset":
-		formFactor = Headset
-	default:
-		err = fmt.Errorf("invalid device form factor: %v", value)
+	formFactor, ok := names.ParseReported(string(value))
+	if !ok {
+		return FormFactor(formFactor), fmt.Errorf("invalid device form factor: %v", value)
+	}
+
+	return FormFactor(formFactor), nil
+}
+
+func (value FormFactor) String() string {
+	return names.Name(uint64(value))
+}
+
+func (value FormFactor) MarshalJSON() ([]byte, error) {
+	return names.Encode(uint64(value))
+}
+
+func (value *FormFactor) UnmarshalJSON(data []byte) error {
+	formFactor, err := names.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid device form factor: %v", err)
 	}
 
-	return
+	*value = FormFactor(formFactor)
+
+	return nil
 }
@@ -1,6 +1,7 @@
 package audioclient
 
 type AudioClient struct {
-	Index           uint64
-	CardDeviceIndex uint64
+	Index           uint64 `json:"index"`
+	CardDeviceIndex uint64 `json:"cardDeviceIndex"`
+	Name            string `json:"name"`
 }
@@ -73,13 +73,10 @@ func Parse(text string, ctx context.Context) []*AudioClient {
 
 			match := re.FindStringSubmatch(value)
 
-			if match[captures["name"]] == "PulseAudio Volume Control" {
-				continue
-			}
-
 			audioClients = append(audioClients, &AudioClient{
 				Index:           clientIndex,
 				CardDeviceIndex: cardDeviceIndex,
+				Name:            match[captures["name"]],
 			})
 		}
 	}
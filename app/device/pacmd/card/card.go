@@ -16,4 +16,5 @@ type Card struct {
 	SinkIds       []uint64              `json:"sinkIds"`
 	FormFactor    formfactor.FormFactor `json:"formFactor"`
 	Bus           bus.Bus               `json:"bus"`
+	BatteryLevel  *uint64               `json:"batteryLevel,omitempty"`
 }
@@ -3,35 +3,65 @@ package carddevice
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/util/enum"
 )
 
 type A2DPCodec uint64
 
 const (
-	SBC A2DPCodec = iota + 1
+	UnknownA2DPCodec A2DPCodec = enum.Unknown
+	SBC              A2DPCodec = iota
 	AAC
 	AptX
+	AptXHD
+	LDAC
+	LC3
+	MSBC
+	FastStream
 )
 
+var a2dpCodecNames = enum.NewNames(map[uint64]string{
+	uint64(SBC):        "sbc",
+	uint64(AAC):        "aac",
+	uint64(AptX):       "aptx",
+	uint64(AptXHD):     "aptx_hd",
+	uint64(LDAC):       "ldac",
+	uint64(LC3):        "lc3",
+	uint64(MSBC):       "msbc",
+	uint64(FastStream): "faststream",
+})
+
 type ParseableA2DPCodec string
 
-func (value ParseableA2DPCodec) Parse(ctx context.Context) (a2dpCodec A2DPCodec, err error) {
+func (value ParseableA2DPCodec) Parse(ctx context.Context) (A2DPCodec, error) {
 	_, span := app.SpanWithContext(ctx, "Parse Device Bluetooth Protocol")
 	defer span.End()
 
-	switch strings.ToLower(string(value)) {
-	case "sbc":
-		a2dpCodec = SBC
-	case "aac":
-		a2dpCodec = AAC
-	case "aptx":
-		a2dpCodec = AptX
-	default:
-		err = fmt.Errorf("Invalid device A2DP codec: %v\n", value)
+	a2dpCodec, ok := a2dpCodecNames.ParseReported(string(value))
+	if !ok {
+		return A2DPCodec(a2dpCodec), fmt.Errorf("Invalid device A2DP codec: %v\n", value)
+	}
+
+	return A2DPCodec(a2dpCodec), nil
+}
+
+func (value A2DPCodec) String() string {
+	return a2dpCodecNames.Name(uint64(value))
+}
+
+func (value A2DPCodec) MarshalJSON() ([]byte, error) {
+	return a2dpCodecNames.Encode(uint64(value))
+}
+
+func (value *A2DPCodec) UnmarshalJSON(data []byte) error {
+	a2dpCodec, err := a2dpCodecNames.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid device A2DP codec: %v", err)
 	}
 
-	return
+	*value = A2DPCodec(a2dpCodec)
+
+	return nil
 }
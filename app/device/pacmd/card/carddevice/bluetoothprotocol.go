@@ -3,32 +3,53 @@ package carddevice
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/util/enum"
 )
 
 type BluetoothProtocol uint64
 
 const (
-	HeadsetHeadUnit BluetoothProtocol = iota + 1
+	UnknownBluetoothProtocol BluetoothProtocol = enum.Unknown
+	HeadsetHeadUnit          BluetoothProtocol = iota
 	A2DPSink
 )
 
+var bluetoothProtocolNames = enum.NewNames(map[uint64]string{
+	uint64(HeadsetHeadUnit): "headset_head_unit",
+	uint64(A2DPSink):        "a2dp_sink",
+})
+
 type ParseableBluetoothProtocol string
 
-func (value ParseableBluetoothProtocol) Parse(ctx context.Context) (bluetoothProtocol BluetoothProtocol, err error) {
+func (value ParseableBluetoothProtocol) Parse(ctx context.Context) (BluetoothProtocol, error) {
 	_, span := app.SpanWithContext(ctx, "Parse Device Bluetooth Protocol")
 	defer span.End()
 
-	switch strings.ToLower(string(value)) {
-	case "headset_head_unit":
-		bluetoothProtocol = HeadsetHeadUnit
-	case "a2dp_sink":
-		bluetoothProtocol = A2DPSink
-	default:
-		err = fmt.Errorf("Invalid device bluetooth protocol: %v\n", value)
+	bluetoothProtocol, ok := bluetoothProtocolNames.ParseReported(string(value))
+	if !ok {
+		return BluetoothProtocol(bluetoothProtocol), fmt.Errorf("Invalid device bluetooth protocol: %v\n", value)
+	}
+
+	return BluetoothProtocol(bluetoothProtocol), nil
+}
+
+func (value BluetoothProtocol) String() string {
+	return bluetoothProtocolNames.Name(uint64(value))
+}
+
+func (value BluetoothProtocol) MarshalJSON() ([]byte, error) {
+	return bluetoothProtocolNames.Encode(uint64(value))
+}
+
+func (value *BluetoothProtocol) UnmarshalJSON(data []byte) error {
+	bluetoothProtocol, err := bluetoothProtocolNames.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid device bluetooth protocol: %v", err)
 	}
 
-	return
+	*value = BluetoothProtocol(bluetoothProtocol)
+
+	return nil
 }
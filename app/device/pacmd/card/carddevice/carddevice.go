@@ -19,4 +19,5 @@ type CardDevice struct {
 	A2DPCodec         A2DPCodec             `json:"a2dpCodec"`
 	FormFactor        formfactor.FormFactor `json:"formFactor"`
 	Bus               bus.Bus               `json:"bus"`
+	BatteryLevel      *uint64               `json:"batteryLevel,omitempty"`
 }
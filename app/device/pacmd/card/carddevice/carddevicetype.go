@@ -2,38 +2,49 @@ package carddevice
 
 import (
 	"fmt"
-	"strings"
+
+	"github.com/sadesyllas/go-cctl/app/util/enum"
 )
 
 type CardDeviceType uint64
 
 const (
-	Source CardDeviceType = iota + 1
+	UnknownCardDeviceType CardDeviceType = enum.Unknown
+	Source                CardDeviceType = iota
 	Sink
 )
 
+var cardDeviceTypeNames = enum.NewNames(map[uint64]string{
+	uint64(Source): "source",
+	uint64(Sink):   "sink",
+})
+
 type ParseableCardDeviceType string
 
-func (value ParseableCardDeviceType) Parse() (cardDeviceType CardDeviceType, err error) {
-	switch strings.ToLower(string(value)) {
-	case "source":
-		cardDeviceType = Source
-	case "sink":
-		cardDeviceType = Sink
-	default:
-		err = fmt.Errorf("Invalid card device type: %v\n", value)
+func (value ParseableCardDeviceType) Parse() (CardDeviceType, error) {
+	cardDeviceType, ok := cardDeviceTypeNames.Parse(string(value))
+	if !ok {
+		return CardDeviceType(cardDeviceType), fmt.Errorf("Invalid card device type: %v\n", value)
 	}
 
-	return
+	return CardDeviceType(cardDeviceType), nil
 }
 
 func (value CardDeviceType) String() string {
-	switch value {
-	case Source:
-		return "source"
-	case Sink:
-		return "sink"
+	return cardDeviceTypeNames.Name(uint64(value))
+}
+
+func (value CardDeviceType) MarshalJSON() ([]byte, error) {
+	return cardDeviceTypeNames.Encode(uint64(value))
+}
+
+func (value *CardDeviceType) UnmarshalJSON(data []byte) error {
+	cardDeviceType, err := cardDeviceTypeNames.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid card device type: %v", err)
 	}
 
-	panic("unreachable")
+	*value = CardDeviceType(cardDeviceType)
+
+	return nil
 }
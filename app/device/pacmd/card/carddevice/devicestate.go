@@ -3,35 +3,55 @@ package carddevice
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/util/enum"
 )
 
 type DeviceState uint64
 
 const (
-	Running DeviceState = iota + 1
+	UnknownDeviceState DeviceState = enum.Unknown
+	Running            DeviceState = iota
 	Idle
 	Suspended
 )
 
+var deviceStateNames = enum.NewNames(map[uint64]string{
+	uint64(Running):   "running",
+	uint64(Idle):      "idle",
+	uint64(Suspended): "suspended",
+})
+
 type ParseableDeviceState string
 
-func (value ParseableDeviceState) Parse(ctx context.Context) (deviceState DeviceState, err error) {
+func (value ParseableDeviceState) Parse(ctx context.Context) (DeviceState, error) {
 	_, span := app.SpanWithContext(ctx, "Parse Device State")
 	defer span.End()
 
-	switch strings.ToLower(string(value)) {
-	case "running":
-		deviceState = Running
-	case "idle":
-		deviceState = Idle
-	case "suspended":
-		deviceState = Suspended
-	default:
-		err = fmt.Errorf("Invalid device state: %v\n", value)
+	deviceState, ok := deviceStateNames.ParseReported(string(value))
+	if !ok {
+		return DeviceState(deviceState), fmt.Errorf("Invalid device state: %v\n", value)
+	}
+
+	return DeviceState(deviceState), nil
+}
+
+func (value DeviceState) String() string {
+	return deviceStateNames.Name(uint64(value))
+}
+
+func (value DeviceState) MarshalJSON() ([]byte, error) {
+	return deviceStateNames.Encode(uint64(value))
+}
+
+func (value *DeviceState) UnmarshalJSON(data []byte) error {
+	deviceState, err := deviceStateNames.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid device state: %v", err)
 	}
 
-	return
+	*value = DeviceState(deviceState)
+
+	return nil
 }
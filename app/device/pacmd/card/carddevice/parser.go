@@ -89,7 +89,10 @@ func Parse(text string, ctx context.Context) []*CardDevice {
 			}
 
 			value := util.UnquoteParsedStringValue(match[captures["value"]])
-			formFactor, _ := formfactor.ParseableFormFactor(value).Parse(ctx)
+			formFactor, err := formfactor.ParseableFormFactor(value).Parse(ctx)
+			if err != nil {
+				util.ParserWarning(ctx, "card_device", "device.form_factor", err)
+			}
 
 			cardDevice.FormFactor = formFactor
 		case "state":
@@ -98,7 +101,10 @@ func Parse(text string, ctx context.Context) []*CardDevice {
 			}
 
 			value := match[captures["value"]]
-			deviceState, _ := ParseableDeviceState(value).Parse(ctx)
+			deviceState, err := ParseableDeviceState(value).Parse(ctx)
+			if err != nil {
+				util.ParserWarning(ctx, "card_device", "state", err)
+			}
 
 			cardDevice.State = deviceState
 		case "volume":
@@ -127,7 +133,10 @@ func Parse(text string, ctx context.Context) []*CardDevice {
 			}
 
 			value := util.UnquoteParsedStringValue(match[captures["value"]])
-			bluetoothProtocol, _ := ParseableBluetoothProtocol(value).Parse(ctx)
+			bluetoothProtocol, err := ParseableBluetoothProtocol(value).Parse(ctx)
+			if err != nil {
+				util.ParserWarning(ctx, "card_device", "bluetooth.protocol", err)
+			}
 
 			cardDevice.BluetoothProtocol = bluetoothProtocol
 		case "bluetooth.a2dp_codec":
@@ -136,16 +145,36 @@ func Parse(text string, ctx context.Context) []*CardDevice {
 			}
 
 			value := util.UnquoteParsedStringValue(match[captures["value"]])
-			a2dpCodec, _ := ParseableA2DPCodec(value).Parse(ctx)
+			a2dpCodec, err := ParseableA2DPCodec(value).Parse(ctx)
+			if err != nil {
+				util.ParserWarning(ctx, "card_device", "bluetooth.a2dp_codec", err)
+			}
 
 			cardDevice.A2DPCodec = a2dpCodec
+		case "bluetooth.battery":
+			if cardDevice == nil {
+				break
+			}
+
+			value := util.UnquoteParsedStringValue(match[captures["value"]])
+			batteryLevel, err := util.ParseBatteryLevel(value)
+			if err != nil {
+				util.ParserWarning(ctx, "card_device", "bluetooth.battery", err)
+
+				break
+			}
+
+			cardDevice.BatteryLevel = &batteryLevel
 		case "device.bus":
 			if cardDevice == nil {
 				break
 			}
 
 			value := util.UnquoteParsedStringValue(match[captures["value"]])
-			bus, _ := bus.ParseableBus(value).Parse(ctx)
+			bus, err := bus.ParseableBus(value).Parse(ctx)
+			if err != nil {
+				util.ParserWarning(ctx, "card_device", "device.bus", err)
+			}
 
 			cardDevice.Bus = bus
 		case "monitor_of":
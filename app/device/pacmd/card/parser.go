@@ -73,7 +73,10 @@ func Parse(text string, ctx context.Context) []*Card {
 			}
 
 			value := util.UnquoteParsedStringValue(match[captures["value"]])
-			profile, _ := ParseableProfile(value).Parse()
+			profile, err := ParseableProfile(value).ParseReported()
+			if err != nil {
+				util.ParserWarning(ctx, "card", "active profile", err)
+			}
 
 			card.ActiveProfile = profile
 		case "sinks":
@@ -85,17 +88,36 @@ func Parse(text string, ctx context.Context) []*Card {
 			inSources = false
 		case "device.bus":
 			value := util.UnquoteParsedStringValue(match[captures["value"]])
-			bus, _ := bus.ParseableBus(value).Parse(ctx)
+			bus, err := bus.ParseableBus(value).Parse(ctx)
+			if err != nil {
+				util.ParserWarning(ctx, "card", "device.bus", err)
+			}
 
 			card.Bus = bus
 		case "device.form_factor":
 			value := util.UnquoteParsedStringValue(match[captures["value"]])
-			formFactor, _ := formfactor.ParseableFormFactor(value).Parse(ctx)
+			formFactor, err := formfactor.ParseableFormFactor(value).Parse(ctx)
+			if err != nil {
+				util.ParserWarning(ctx, "card", "device.form_factor", err)
+			}
 
 			card.FormFactor = formFactor
+		case "bluetooth.battery":
+			value := util.UnquoteParsedStringValue(match[captures["value"]])
+			batteryLevel, err := util.ParseBatteryLevel(value)
+			if err != nil {
+				util.ParserWarning(ctx, "card", "bluetooth.battery", err)
+
+				break
+			}
+
+			card.BatteryLevel = &batteryLevel
 		default:
 			if inProfiles && card.Bus == bus.Bluetooth {
-				profile, _ := ParseableProfile(key).Parse()
+				profile, err := ParseableProfile(key).ParseReported()
+				if err != nil {
+					util.ParserWarning(ctx, "card", "profiles", err)
+				}
 				card.Profiles = append(card.Profiles, profile)
 			}
 
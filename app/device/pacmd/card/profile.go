@@ -2,13 +2,15 @@ package card
 
 import (
 	"fmt"
-	"strings"
+
+	"github.com/sadesyllas/go-cctl/app/util/enum"
 )
 
 type CardProfile uint64
 
 const (
-	HeadsetHeadUnit CardProfile = iota + 1
+	UnknownProfile  CardProfile = enum.Unknown
+	HeadsetHeadUnit CardProfile = iota
 	A2DPSinkSBC
 	A2DPSinkAAC
 	A2DPSinkAptX
@@ -17,46 +19,52 @@ const (
 	Off
 )
 
+var profileNames = enum.NewNames(map[uint64]string{
+	uint64(HeadsetHeadUnit): "headset_head_unit",
+	uint64(A2DPSinkSBC):     "a2dp_sink_sbc",
+	uint64(A2DPSinkAAC):     "a2dp_sink_aac",
+	uint64(A2DPSinkAptX):    "a2dp_sink_aptx",
+	uint64(A2DPSinkAptXHD):  "a2dp_sink_aptx_hd",
+	uint64(A2DPSinkLDAC):    "a2dp_sink_ldac",
+	uint64(Off):             "off",
+})
+
 type ParseableProfile string
 
-func (value ParseableProfile) Parse() (cardProfile CardProfile, err error) {
-	switch strings.ToLower(string(value)) {
-	case "headset_head_unit":
-		cardProfile = HeadsetHeadUnit
-	case "a2dp_sink_sbc":
-		cardProfile = A2DPSinkSBC
-	case "a2dp_sink_aac":
-		cardProfile = A2DPSinkAAC
-	case "a2dp_sink_aptx":
-		cardProfile = A2DPSinkAptX
-	case "a2dp_sink_aptx_hd":
-		cardProfile = A2DPSinkAptXHD
-	case "a2dp_sink_ldac":
-		cardProfile = A2DPSinkLDAC
-	case "off":
-		cardProfile = Off
-	default:
-		err = fmt.Errorf("invalid card profile: %v\n", value)
+func (value ParseableProfile) Parse() (CardProfile, error) {
+	cardProfile, ok := profileNames.Parse(string(value))
+	if !ok {
+		return CardProfile(cardProfile), fmt.Errorf("invalid card profile: %v\n", value)
+	}
+
+	return CardProfile(cardProfile), nil
+}
+
+// ParseReported is Parse for the profiles which pacmd reports, which keeps the name of a profile that is not known.
+func (value ParseableProfile) ParseReported() (CardProfile, error) {
+	cardProfile, ok := profileNames.ParseReported(string(value))
+	if !ok {
+		return CardProfile(cardProfile), fmt.Errorf("invalid card profile: %v\n", value)
 	}
 
-	return
+	return CardProfile(cardProfile), nil
 }
 
 func (value CardProfile) String() string {
-	switch value {
-	case HeadsetHeadUnit:
-		return "headset_head_unit"
-	case A2DPSinkSBC:
-		return "a2dp_sink_sbc"
-	case A2DPSinkAAC:
-		return "a2dp_sink_aac"
-	case A2DPSinkAptX:
-		return "a2dp_sink_aptx"
-	case A2DPSinkAptXHD:
-		return "a2dp_sink_aptx_hd"
-	case A2DPSinkLDAC:
-		return "a2dp_sink_ldac"
+	return profileNames.Name(uint64(value))
+}
+
+func (value CardProfile) MarshalJSON() ([]byte, error) {
+	return profileNames.Encode(uint64(value))
+}
+
+func (value *CardProfile) UnmarshalJSON(data []byte) error {
+	cardProfile, err := profileNames.Decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid card profile: %v", err)
 	}
 
-	panic("unreachable")
+	*value = CardProfile(cardProfile)
+
+	return nil
 }
@@ -1,105 +1,389 @@
 package pubsub
 
 import (
-	"math"
+	"context"
+	"fmt"
+	"strings"
 	"sync"
-	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sadesyllas/go-cctl/app"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Topic uint64
 
 const (
 	TopicDeviceState Topic = iota + 1
+	TopicDeviceAdded
+	TopicDeviceRemoved
+	TopicVolumeChanged
+	TopicMuteChanged
+	TopicDefaultChanged
+	TopicProfileChanged
+	TopicStreamMoved
 )
 
+type ParseableTopic string
+
+func (value ParseableTopic) Parse() (topic Topic, err error) {
+	switch strings.ToLower(string(value)) {
+	case "device_state":
+		topic = TopicDeviceState
+	case "device_added":
+		topic = TopicDeviceAdded
+	case "device_removed":
+		topic = TopicDeviceRemoved
+	case "volume_changed":
+		topic = TopicVolumeChanged
+	case "mute_changed":
+		topic = TopicMuteChanged
+	case "default_changed":
+		topic = TopicDefaultChanged
+	case "profile_changed":
+		topic = TopicProfileChanged
+	case "stream_moved":
+		topic = TopicStreamMoved
+	default:
+		err = fmt.Errorf("invalid topic: %v", value)
+	}
+
+	return
+}
+
+func (value Topic) String() string {
+	switch value {
+	case TopicDeviceState:
+		return "device_state"
+	case TopicDeviceAdded:
+		return "device_added"
+	case TopicDeviceRemoved:
+		return "device_removed"
+	case TopicVolumeChanged:
+		return "volume_changed"
+	case TopicMuteChanged:
+		return "mute_changed"
+	case TopicDefaultChanged:
+		return "default_changed"
+	case TopicProfileChanged:
+		return "profile_changed"
+	case TopicStreamMoved:
+		return "stream_moved"
+	}
+
+	panic("unreachable")
+}
+
+// Message is built by the TypedTopic of its topic, which decides the type of its payload.
 type Message struct {
 	Topic   Topic
-	Payload interface{}
+	payload interface{}
+	// SpanContext identifies the span during which the message was published, so that subscribers can continue
+	// the same trace.
+	SpanContext trace.SpanContext
+}
+
+// Payload returns the payload for subscribers which handle every topic alike, e.g. by encoding it. The others
+// read it through the TypedTopic of the topic.
+func (msg Message) Payload() interface{} {
+	return msg.payload
+}
+
+// TypedTopic is a topic along with the type of its payload, through which its messages are built and read without
+// type assertions.
+type TypedTopic[T any] struct {
+	Topic Topic
 }
 
-func NewMessage(topic Topic, payload interface{}) Message {
+func NewTypedTopic[T any](topic Topic) TypedTopic[T] {
+	return TypedTopic[T]{Topic: topic}
+}
+
+// Message returns a message of the topic, carrying payload.
+func (topic TypedTopic[T]) Message(payload T) Message {
 	return Message{
-		Topic:   topic,
-		Payload: payload,
+		Topic:   topic.Topic,
+		payload: payload,
+	}
+}
+
+// Publish publishes payload as part of the trace of ctx.
+func (topic TypedTopic[T]) Publish(bus *Bus, payload T, ctx context.Context) {
+	bus.Publish(topic.Message(payload).WithContext(ctx))
+}
+
+// Subscribe registers for the messages of the topic alone, whose payloads are read with Payload.
+func (topic TypedTopic[T]) Subscribe(bus *Bus, name string, policy Policy, size int) *Subscription {
+	return bus.Register(name, policy, size, topic.Topic)
+}
+
+// Payload returns the payload of a message of the topic, along with false for a message of another topic.
+func (topic TypedTopic[T]) Payload(msg Message) (T, bool) {
+	payload, ok := msg.payload.(T)
+
+	return payload, ok && msg.Topic == topic.Topic
+}
+
+// WithContext returns a copy of the message which carries the span of the given context.
+func (msg Message) WithContext(ctx context.Context) Message {
+	msg.SpanContext = trace.SpanContextFromContext(ctx)
+
+	return msg
+}
+
+// Context returns a context for handling the message, which continues the trace it was published in, if any.
+func (msg Message) Context() context.Context {
+	if !msg.SpanContext.IsValid() {
+		return context.Background()
+	}
+
+	return trace.ContextWithRemoteSpanContext(context.Background(), msg.SpanContext)
+}
+
+// Policy decides what happens when a message is published while the queue of a subscription is full.
+type Policy uint64
+
+const (
+	// DropOldest discards the oldest queued message to make room for the new one, so that a slow
+	// subscriber never stalls the publisher.
+	DropOldest Policy = iota + 1
+	// Block makes the publisher wait until the subscriber has made room in its queue.
+	Block
+)
+
+func (value Policy) String() string {
+	switch value {
+	case DropOldest:
+		return "drop_oldest"
+	case Block:
+		return "block"
+	}
+
+	panic("unreachable")
+}
+
+var deliveredCnt = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cctl_pubsub_delivered_total",
+		Help: "Total number of messages queued for a subscriber.",
+	},
+	[]string{"subscriber", "topic"},
+)
+
+var droppedCnt = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cctl_pubsub_dropped_total",
+		Help: "Total number of messages dropped because a subscriber's queue was full.",
+	},
+	[]string{"subscriber", "topic"},
+)
+
+var queueLengthDesc = prometheus.NewDesc(
+	"cctl_pubsub_queue_length",
+	"Number of messages waiting in the queues of the subscribers.",
+	[]string{"subscriber"},
+	nil,
+)
+
+var subscriptionsGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "cctl_pubsub_subscriptions",
+		Help: "Number of active subscriptions.",
+	},
+	[]string{"subscriber"},
+)
+
+// Bus fans out published messages to the subscriptions registered for their topic.
+//
+// Each subscription owns a buffered queue, so that delivering to one subscriber does not depend on how fast
+// any other subscriber consumes its messages.
+//
+// Bus is a prometheus.Collector reporting the queue length of its subscribers.
+type Bus struct {
+	lock          sync.RWMutex
+	subscriptions map[Topic]map[*Subscription]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscriptions: make(map[Topic]map[*Subscription]struct{}),
 	}
 }
 
-var inbound = make(chan Message)
-var subscriptions = make(map[Topic][]chan<- Message)
-var singletonLock sync.Mutex
-var started = false
+// Subscription is the receiving end of a registration on a Bus.
+type Subscription struct {
+	name   string
+	topics []Topic
+	policy Policy
+	queue  chan Message
+	done   chan struct{}
+	lock   sync.Mutex
+	closed bool
+	once   sync.Once
+	bus    *Bus
+}
 
-func Start() {
-	defer func() { app.Logger.Fatal("PubSub has stopped") }()
+// Register subscribes to the given topics with a queue of size messages.
+//
+// The name labels the metrics of the subscription and does not need to be unique.
+func (bus *Bus) Register(name string, policy Policy, size int, topics ...Topic) *Subscription {
+	if size < 1 {
+		size = 1
+	}
 
-	doStart := func() bool {
-		singletonLock.Lock()
-		defer singletonLock.Unlock()
-		if started {
-			return false
-		} else {
-			started = true
-			return true
+	subscription := &Subscription{
+		name:   name,
+		topics: topics,
+		policy: policy,
+		queue:  make(chan Message, size),
+		done:   make(chan struct{}),
+		bus:    bus,
+	}
+
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	for _, topic := range topics {
+		if _, ok := bus.subscriptions[topic]; !ok {
+			bus.subscriptions[topic] = make(map[*Subscription]struct{})
 		}
-	}()
 
-	if !doStart {
-		return
+		bus.subscriptions[topic][subscription] = struct{}{}
 	}
 
-	for msg := range inbound {
-		func() {
-			singletonLock.Lock()
-			defer singletonLock.Unlock()
-			if ch, ok := subscriptions[msg.Topic]; ok {
-				for i, c := range ch {
-					timer := time.NewTimer(time.Second)
-					timerExpired := false
-					done := false
-
-					for {
-						if done {
-							break
-						}
-
-						select {
-						case c <- msg:
-							done = true
-						default:
-							select {
-							case <-timer.C:
-								timerExpired = true
-								done = true
-							default:
-								time.Sleep(10 * time.Millisecond)
-							}
-						}
-					}
-
-					if timerExpired {
-						subscriptions[msg.Topic] = append(ch[:i], ch[int(math.Min(float64(i+1), float64(len(ch)))):]...)
-
-						app.Logger.Info("PubSub evicted timed out receiving channel")
-					}
-				}
+	subscriptionsGauge.WithLabelValues(name).Inc()
+
+	return subscription
+}
+
+// Unregister removes the subscription from the bus and closes its channel.
+func (bus *Bus) Unregister(subscription *Subscription) {
+	if subscription.bus != bus {
+		panic(fmt.Sprintf("subscription %v does not belong to this bus", subscription.name))
+	}
+
+	subscription.Close()
+}
+
+// Publish queues the message for every subscription registered for its topic.
+func (bus *Bus) Publish(msg Message) {
+	bus.lock.RLock()
+	subscriptions := make([]*Subscription, 0, len(bus.subscriptions[msg.Topic]))
+	for subscription := range bus.subscriptions[msg.Topic] {
+		subscriptions = append(subscriptions, subscription)
+	}
+	bus.lock.RUnlock()
+
+	for _, subscription := range subscriptions {
+		subscription.deliver(msg)
+	}
+}
+
+func (bus *Bus) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueLengthDesc
+}
+
+func (bus *Bus) Collect(ch chan<- prometheus.Metric) {
+	bus.lock.RLock()
+	queueLengths := make(map[string]int)
+	seen := make(map[*Subscription]struct{})
+	for _, subscriptions := range bus.subscriptions {
+		for subscription := range subscriptions {
+			if _, ok := seen[subscription]; ok {
+				continue
 			}
-		}()
+
+			seen[subscription] = struct{}{}
+			queueLengths[subscription.name] += len(subscription.queue)
+		}
+	}
+	bus.lock.RUnlock()
+
+	for name, queueLength := range queueLengths {
+		ch <- prometheus.MustNewConstMetric(queueLengthDesc, prometheus.GaugeValue, float64(queueLength), name)
 	}
 }
 
-func Register(topic Topic, ch chan<- Message) {
-	singletonLock.Lock()
-	defer singletonLock.Unlock()
+func (bus *Bus) remove(subscription *Subscription) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	for _, topic := range subscription.topics {
+		delete(bus.subscriptions[topic], subscription)
 
-	if _, ok := subscriptions[topic]; !ok {
-		subscriptions[topic] = make([]chan<- Message, 0)
+		if len(bus.subscriptions[topic]) == 0 {
+			delete(bus.subscriptions, topic)
+		}
 	}
+}
+
+// C returns the channel the messages of the subscription are received from.
+//
+// The channel is closed when the subscription is closed.
+func (subscription *Subscription) C() <-chan Message {
+	return subscription.queue
+}
+
+// Name returns the name the subscription was registered with.
+func (subscription *Subscription) Name() string {
+	return subscription.name
+}
+
+// Close unregisters the subscription from its bus and closes its channel.
+//
+// It is safe to call Close more than once and concurrently with Publish.
+func (subscription *Subscription) Close() {
+	subscription.once.Do(func() {
+		subscription.bus.remove(subscription)
+
+		// Releases a publisher blocked on a full queue, before taking the lock it holds.
+		close(subscription.done)
+
+		subscription.lock.Lock()
+		defer subscription.lock.Unlock()
+
+		subscription.closed = true
+		close(subscription.queue)
 
-	subscriptions[topic] = append(subscriptions[topic], ch)
+		subscriptionsGauge.WithLabelValues(subscription.name).Dec()
+	})
 }
 
-func Send(msg Message) {
-	inbound <- msg
+func (subscription *Subscription) deliver(msg Message) {
+	subscription.lock.Lock()
+	defer subscription.lock.Unlock()
+
+	if subscription.closed {
+		return
+	}
+
+	topic := msg.Topic.String()
+
+	switch subscription.policy {
+	case Block:
+		select {
+		case subscription.queue <- msg:
+		case <-subscription.done:
+			return
+		}
+	default:
+		for queued := false; !queued; {
+			select {
+			case subscription.queue <- msg:
+				queued = true
+			default:
+				select {
+				case <-subscription.queue:
+					droppedCnt.WithLabelValues(subscription.name, topic).Inc()
+
+					app.Logger.Debugw("PubSub dropped the oldest queued message",
+						"subscriber", subscription.name, "topic", topic)
+				default:
+				}
+			}
+		}
+	}
+
+	deliveredCnt.WithLabelValues(subscription.name, topic).Inc()
 }
@@ -0,0 +1,188 @@
+package pubsub
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sadesyllas/go-cctl/app"
+	"go.uber.org/zap"
+)
+
+var testTopic = NewTypedTopic[int](TopicVolumeChanged)
+
+func TestMain(m *testing.M) {
+	app.Logger = zap.NewNop().Sugar()
+
+	os.Exit(m.Run())
+}
+
+// receive returns the payloads of the next n messages of the subscription, or fails when they do not arrive.
+func receive(t *testing.T, subscription *Subscription, n int) []int {
+	t.Helper()
+
+	payloads := []int{}
+	for len(payloads) < n {
+		select {
+		case msg, ok := <-subscription.C():
+			if !ok {
+				t.Fatalf("channel closed after %v", payloads)
+			}
+
+			payload, _ := testTopic.Payload(msg)
+			payloads = append(payloads, payload)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %v", payloads)
+		}
+	}
+
+	return payloads
+}
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		size    int
+		publish int
+		blocks  bool
+		want    []int
+	}{
+		{"drop oldest within size", DropOldest, 4, 3, false, []int{1, 2, 3}},
+		{"drop oldest when full", DropOldest, 2, 4, false, []int{3, 4}},
+		{"drop oldest with size below one", DropOldest, 0, 3, false, []int{3}},
+		{"block within size", Block, 4, 3, false, []int{1, 2, 3}},
+		{"block when full", Block, 2, 4, true, []int{1, 2, 3, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := NewBus()
+			subscription := testTopic.Subscribe(bus, "test", test.policy, test.size)
+			defer subscription.Close()
+
+			published := make(chan struct{})
+			go func() {
+				defer close(published)
+
+				for i := 1; i <= test.publish; i++ {
+					testTopic.Publish(bus, i, context.Background())
+				}
+			}()
+
+			blocked := false
+			select {
+			case <-published:
+			case <-time.After(100 * time.Millisecond):
+				blocked = true
+			}
+
+			if blocked != test.blocks {
+				t.Errorf("publisher blocked = %v, want %v", blocked, test.blocks)
+			}
+
+			if got := receive(t, subscription, len(test.want)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("received %v, want %v", got, test.want)
+			}
+
+			select {
+			case <-published:
+			case <-time.After(time.Second):
+				t.Fatal("publisher still blocked after the queue was drained")
+			}
+
+			if queued := len(subscription.C()); queued != 0 {
+				t.Errorf("%v messages left in the queue, want none", queued)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(bus *Bus, subscription *Subscription)
+	}{
+		{"close", func(bus *Bus, subscription *Subscription) { subscription.Close() }},
+		{"close twice", func(bus *Bus, subscription *Subscription) {
+			subscription.Close()
+			subscription.Close()
+		}},
+		{"unregister", func(bus *Bus, subscription *Subscription) { bus.Unregister(subscription) }},
+		{"unregister after close", func(bus *Bus, subscription *Subscription) {
+			subscription.Close()
+			bus.Unregister(subscription)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := NewBus()
+			closed := testTopic.Subscribe(bus, "closed", Block, 1)
+			open := testTopic.Subscribe(bus, "open", Block, 1)
+			defer open.Close()
+
+			test.close(bus, closed)
+
+			if _, ok := <-closed.C(); ok {
+				t.Error("received from a closed subscription")
+			}
+
+			testTopic.Publish(bus, 1, context.Background())
+
+			if got := receive(t, open, 1); !reflect.DeepEqual(got, []int{1}) {
+				t.Errorf("open subscription received %v, want [1]", got)
+			}
+
+			bus.lock.RLock()
+			_, registered := bus.subscriptions[testTopic.Topic][closed]
+			bus.lock.RUnlock()
+
+			if registered {
+				t.Error("closed subscription is still registered")
+			}
+		})
+	}
+}
+
+func TestCloseReleasesBlockedPublisher(t *testing.T) {
+	bus := NewBus()
+	subscription := testTopic.Subscribe(bus, "test", Block, 1)
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+
+		testTopic.Publish(bus, 1, context.Background())
+		testTopic.Publish(bus, 2, context.Background())
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("publisher did not block on a full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	subscription.Close()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publisher still blocked after the subscription was closed")
+	}
+}
+
+func TestUnregisterFromAnotherBus(t *testing.T) {
+	subscription := testTopic.Subscribe(NewBus(), "test", Block, 1)
+	defer subscription.Close()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("unregistering from another bus did not panic")
+		}
+	}()
+
+	NewBus().Unregister(subscription)
+}
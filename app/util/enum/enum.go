@@ -0,0 +1,153 @@
+package enum
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Unknown is the value of an enum which has not been reported at all, named "unknown".
+const Unknown = 0
+
+const unknownName = "unknown"
+
+// firstUnknownValue is the value given to the first name which is not known, far above the known values.
+const firstUnknownValue = 1 << 32
+
+// maxUnknownNames bounds how many names which are not known are kept per enum, beyond which they become Unknown.
+const maxUnknownNames = 256
+
+// Names maps the values of an enum to their names, and back.
+//
+// A name which is not known and has been reported by the sound server, e.g. a codec which it has learnt after
+// go-cctl, is given a value of its own, so that it can still be reported as it was read, while comparing unequal to
+// every known value. The names which users give are never kept, so that they cannot use up the room for these.
+//
+// The enums of go-cctl are built on Names, so that, alike:
+//
+//   - Parse returns Unknown for a name which is not known, along with an error, while the parsers of the output of
+//     pacmd use ParseReported, which returns a value which keeps the name
+//   - String returns "unknown" for a value which was not reported, and the name it was read with for a value which
+//     is not known
+//   - UnmarshalJSON accepts a name, or a number, which is how the values were encoded before they had names
+type Names struct {
+	names  map[uint64]string
+	values map[string]uint64
+
+	lock          sync.RWMutex
+	unknownNames  []string
+	unknownValues map[string]uint64
+}
+
+func NewNames(names map[uint64]string) *Names {
+	values := make(map[string]uint64, len(names))
+	for value, name := range names {
+		values[name] = value
+	}
+
+	return &Names{
+		names:         names,
+		values:        values,
+		unknownValues: make(map[string]uint64),
+	}
+}
+
+// Name returns the name of value, which is the name it was read with when it is not known, or "unknown".
+func (names *Names) Name(value uint64) string {
+	if name, ok := names.names[value]; ok {
+		return name
+	}
+
+	if value >= firstUnknownValue {
+		names.lock.RLock()
+		defer names.lock.RUnlock()
+
+		if i := value - firstUnknownValue; i < uint64(len(names.unknownNames)) {
+			return names.unknownNames[i]
+		}
+	}
+
+	return unknownName
+}
+
+// Value returns the value of a known name, which is matched case insensitively.
+func (names *Names) Value(name string) (uint64, bool) {
+	value, ok := names.values[strings.ToLower(name)]
+
+	return value, ok
+}
+
+// Parse returns the value of a known name or else Unknown, along with false.
+func (names *Names) Parse(name string) (uint64, bool) {
+	if value, ok := names.Value(name); ok {
+		return value, true
+	}
+
+	return Unknown, false
+}
+
+// ParseReported returns the value of a known name or else the value which keeps the name, along with false. It is
+// meant for the names which the sound server reports, since the names it keeps are bounded by maxUnknownNames.
+func (names *Names) ParseReported(name string) (uint64, bool) {
+	if value, ok := names.Value(name); ok {
+		return value, true
+	}
+
+	return names.unknown(name), false
+}
+
+func (names *Names) unknown(name string) uint64 {
+	if name == "" || name == unknownName {
+		return Unknown
+	}
+
+	names.lock.RLock()
+	value, ok := names.unknownValues[name]
+	names.lock.RUnlock()
+
+	if ok {
+		return value
+	}
+
+	names.lock.Lock()
+	defer names.lock.Unlock()
+
+	if value, ok := names.unknownValues[name]; ok {
+		return value
+	}
+
+	if len(names.unknownNames) == maxUnknownNames {
+		return Unknown
+	}
+
+	value = firstUnknownValue + uint64(len(names.unknownNames))
+
+	names.unknownNames = append(names.unknownNames, name)
+	names.unknownValues[name] = value
+
+	return value
+}
+
+// Encode returns the JSON string of the name of value.
+func (names *Names) Encode(value uint64) ([]byte, error) {
+	return json.Marshal(names.Name(value))
+}
+
+// Decode accepts a name, or a number, which is how the values were encoded before they had names. A name which is not
+// known is decoded as Unknown, like in Parse, since JSON comes from the users.
+func (names *Names) Decode(data []byte) (uint64, error) {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		value, _ := names.Parse(name)
+
+		return value, nil
+	}
+
+	var value uint64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return 0, fmt.Errorf("expected a name or a number, got %s", data)
+	}
+
+	return value, nil
+}
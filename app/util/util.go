@@ -1,7 +1,22 @@
 package util
 
-import "regexp"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 func UnquoteParsedStringValue(value string) string {
 	return regexp.MustCompile(`^(?:<|\")|(?:>|\")$`).ReplaceAllString(value, "")
 }
+
+// ParseBatteryLevel parses a battery percentage, e.g. "80%", as the sound server reports it for Bluetooth devices.
+func ParseBatteryLevel(value string) (uint64, error) {
+	batteryLevel, err := strconv.ParseUint(strings.TrimSuffix(value, "%"), 10, 0)
+	if err != nil || batteryLevel > 100 {
+		return 0, fmt.Errorf("invalid battery level: %v", value)
+	}
+
+	return batteryLevel, nil
+}
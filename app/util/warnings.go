@@ -0,0 +1,24 @@
+package util
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sadesyllas/go-cctl/app"
+)
+
+var parserWarningCnt = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cctl_parser_warnings_total",
+		Help: "Total number of pacmd output values which could not be parsed, by parser and field.",
+	},
+	[]string{"parser", "field"},
+)
+
+// ParserWarning records a value of the pacmd output which could not be parsed, and which is kept as it was read.
+func ParserWarning(ctx context.Context, parser string, field string, err error) {
+	parserWarningCnt.WithLabelValues(parser, field).Inc()
+
+	app.LoggerWithContext(ctx).Debugw("Could not parse a pacmd value", "parser", parser, "field", field, "error", err)
+}
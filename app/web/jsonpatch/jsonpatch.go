@@ -0,0 +1,140 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Operation is a single RFC 6902 JSON Patch operation.
+//
+// Only the add, remove and replace operations are ever produced.
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON leaves out the value of remove operations, while keeping null values of the others.
+func (operation Operation) MarshalJSON() ([]byte, error) {
+	if operation.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{operation.Op, operation.Path})
+	}
+
+	type plainOperation Operation
+
+	return json.Marshal(plainOperation(operation))
+}
+
+type Patch []Operation
+
+// Create returns the patch which turns the JSON encoding of from into the JSON encoding of to.
+func Create(from interface{}, to interface{}) (Patch, error) {
+	fromDocument, err := toDocument(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toDocument, err := toDocument(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff("", fromDocument, toDocument, Patch{}), nil
+}
+
+func toDocument(value interface{}) (interface{}, error) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the JSON patch document: %v", err)
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(bytes, &document); err != nil {
+		return nil, fmt.Errorf("could not decode the JSON patch document: %v", err)
+	}
+
+	return document, nil
+}
+
+func diff(path string, from interface{}, to interface{}, patch Patch) Patch {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		if toValue, ok := to.(map[string]interface{}); ok {
+			return diffObjects(path, fromValue, toValue, patch)
+		}
+	case []interface{}:
+		if toValue, ok := to.([]interface{}); ok {
+			return diffArrays(path, fromValue, toValue, patch)
+		}
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		patch = append(patch, Operation{Op: "replace", Path: path, Value: to})
+	}
+
+	return patch
+}
+
+func diffObjects(path string, from map[string]interface{}, to map[string]interface{}, patch Patch) Patch {
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	// Keeps the generated patches stable, regardless of the map iteration order.
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path + "/" + escape(key)
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+
+		switch {
+		case !inTo:
+			patch = append(patch, Operation{Op: "remove", Path: keyPath})
+		case !inFrom:
+			patch = append(patch, Operation{Op: "add", Path: keyPath, Value: toValue})
+		default:
+			patch = diff(keyPath, fromValue, toValue, patch)
+		}
+	}
+
+	return patch
+}
+
+func diffArrays(path string, from []interface{}, to []interface{}, patch Patch) Patch {
+	common := len(from)
+	if len(to) < common {
+		common = len(to)
+	}
+
+	for i := 0; i < common; i++ {
+		patch = diff(fmt.Sprintf("%v/%v", path, i), from[i], to[i], patch)
+	}
+
+	// Removes from the end, so that the indexes of the elements yet to be removed do not shift.
+	for i := len(from) - 1; i >= common; i-- {
+		patch = append(patch, Operation{Op: "remove", Path: fmt.Sprintf("%v/%v", path, i)})
+	}
+
+	for i := common; i < len(to); i++ {
+		patch = append(patch, Operation{Op: "add", Path: path + "/-", Value: to[i]})
+	}
+
+	return patch
+}
+
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,178 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCreateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{"unchanged", `{"sinks":[{"index":1,"volume":50}]}`, `{"sinks":[{"index":1,"volume":50}]}`},
+		{"replaced value", `{"volume":50,"isMuted":false}`, `{"volume":75,"isMuted":true}`},
+		{"added and removed keys", `{"a":1,"b":2}`, `{"b":2,"c":3}`},
+		{"null value", `{"card":{"index":1}}`, `{"card":null}`},
+		{"array grown", `{"sinks":[1]}`, `{"sinks":[1,2,3]}`},
+		{"array shrunk", `{"sinks":[1,2,3,4]}`, `{"sinks":[1]}`},
+		{"array emptied", `{"sinks":[1,2]}`, `{"sinks":[]}`},
+		{"array elements changed", `{"sinks":[{"index":1},{"index":2}]}`, `{"sinks":[{"index":3}]}`},
+		{"type changed", `{"sinks":[1,2]}`, `{"sinks":{"0":1}}`},
+		{"escaped keys", `{"a/b":1,"c~d":2,"~/":{"x":1}}`, `{"a/b":2,"~1":3,"~/":{"x":2}}`},
+		{"root replaced", `[1,2]`, `"none"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to := decode(t, test.from), decode(t, test.to)
+
+			patch, err := Create(from, to)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			// The patch is applied as it is sent, i.e. after a JSON round trip.
+			var operations []map[string]interface{}
+			encodedPatch, _ := json.Marshal(patch)
+			if err := json.Unmarshal(encodedPatch, &operations); err != nil {
+				t.Fatalf("could not decode the patch %s: %v", encodedPatch, err)
+			}
+
+			patched, err := apply(from, operations)
+			if err != nil {
+				t.Fatalf("could not apply the patch %s: %v", encodedPatch, err)
+			}
+
+			if !reflect.DeepEqual(patched, to) {
+				t.Errorf("applying %s to %v = %v, want %v", encodedPatch, test.from, patched, test.to)
+			}
+
+			if test.from == test.to && len(patch) != 0 {
+				t.Errorf("Create() = %s, want an empty patch", encodedPatch)
+			}
+		})
+	}
+}
+
+func TestCreateEscapesKeys(t *testing.T) {
+	patch, err := Create(map[string]int{"a/b~c": 1}, map[string]int{"a/b~c": 2})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if len(patch) != 1 || patch[0].Path != "/a~1b~0c" {
+		t.Errorf("Create() = %v, want a single replace of /a~1b~0c", patch)
+	}
+}
+
+func TestRemoveOperationHasNoValue(t *testing.T) {
+	encoded, _ := json.Marshal(Operation{Op: "remove", Path: "/a"})
+	if string(encoded) != `{"op":"remove","path":"/a"}` {
+		t.Errorf("json.Marshal() = %s", encoded)
+	}
+
+	encoded, _ = json.Marshal(Operation{Op: "replace", Path: "/a"})
+	if string(encoded) != `{"op":"replace","path":"/a","value":null}` {
+		t.Errorf("json.Marshal() = %s", encoded)
+	}
+}
+
+func decode(t *testing.T, data string) interface{} {
+	var document interface{}
+	if err := json.Unmarshal([]byte(data), &document); err != nil {
+		t.Fatalf("invalid test document %v: %v", data, err)
+	}
+
+	return document
+}
+
+// apply applies the add, remove and replace operations of RFC 6902, as a client of the patches would.
+func apply(document interface{}, operations []map[string]interface{}) (interface{}, error) {
+	for _, operation := range operations {
+		var err error
+
+		document, err = applyOperation(document, operation["op"].(string), pointer(operation["path"].(string)),
+			operation["value"])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return document, nil
+}
+
+func applyOperation(document interface{}, op string, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, nil
+		}
+
+		return value, nil
+	}
+
+	token, rest := tokens[0], tokens[1:]
+
+	switch container := document.(type) {
+	case map[string]interface{}:
+		if len(rest) != 0 {
+			child, err := applyOperation(container[token], op, rest, value)
+			container[token] = child
+
+			return container, err
+		}
+
+		if op == "remove" {
+			delete(container, token)
+		} else {
+			container[token] = value
+		}
+
+		return container, nil
+	case []interface{}:
+		if token == "-" && len(rest) == 0 && op == "add" {
+			return append(container, value), nil
+		}
+
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(container) {
+			return nil, fmt.Errorf("invalid array index %v", token)
+		}
+
+		if len(rest) != 0 {
+			container[i], err = applyOperation(container[i], op, rest, value)
+
+			return container, err
+		}
+
+		switch op {
+		case "remove":
+			return append(container[:i], container[i+1:]...), nil
+		case "replace":
+			container[i] = value
+
+			return container, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported %v of %v", op, token)
+}
+
+// pointer splits a JSON pointer into its unescaped reference tokens.
+func pointer(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens
+}
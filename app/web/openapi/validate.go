@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FieldError reports why the value at Field, e.g. "volume" or "profiles[2]", does not match its schema.
+//
+// Field is empty when the value as a whole does not match.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (fieldError FieldError) Error() string {
+	if fieldError.Field == "" {
+		return fieldError.Message
+	}
+
+	return fmt.Sprintf("%v: %v", fieldError.Field, fieldError.Message)
+}
+
+// ValidationError lists every field of a request which does not match its schema.
+type ValidationError struct {
+	Request string
+	Errors  []FieldError
+}
+
+func (validationError *ValidationError) Error() string {
+	messages := make([]string, len(validationError.Errors))
+	for i, fieldError := range validationError.Errors {
+		messages[i] = fieldError.Error()
+	}
+
+	return fmt.Sprintf("bad %v request: %v", validationError.Request, strings.Join(messages, "; "))
+}
+
+// Validate checks the JSON in data against the schema, resolving references against the schemas of the document.
+//
+// It supports the keywords which go-cctl describes its requests with, i.e. type, properties, required, items,
+// enum, minimum, maximum, minLength, nullable and allOf, and ignores the others.
+func (document *Document) Validate(schema *Schema, data []byte) []FieldError {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []FieldError{{Message: "must be valid JSON"}}
+	}
+
+	return document.validate(schema, value, "", nil)
+}
+
+func (document *Document) validate(schema *Schema, value interface{}, field string, errs []FieldError) []FieldError {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+
+		resolved, ok := document.Components.Schemas[name]
+		if !ok {
+			return append(errs, FieldError{Field: field, Message: fmt.Sprintf("refers to unknown schema %v", name)})
+		}
+
+		schema = resolved
+	}
+
+	for _, subschema := range schema.AllOf {
+		errs = document.validate(subschema, value, field, errs)
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return errs
+		}
+
+		return append(errs, FieldError{Field: field, Message: "must not be null"})
+	}
+
+	if message := checkType(schema.Type, value); message != "" {
+		return append(errs, FieldError{Field: field, Message: message})
+	}
+
+	if len(schema.Enum) != 0 && !inEnum(schema.Enum, value) {
+		errs = append(errs, FieldError{Field: field, Message: "must be one of: " + formatEnum(schema.Enum)})
+	}
+
+	switch value := value.(type) {
+	case float64:
+		if schema.Minimum != nil && value < *schema.Minimum {
+			errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf("must be at least %v", *schema.Minimum)})
+		}
+
+		if schema.Maximum != nil && value > *schema.Maximum {
+			errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf("must be at most %v", *schema.Maximum)})
+		}
+	case string:
+		if schema.MinLength > 0 && len([]rune(value)) < schema.MinLength {
+			message := fmt.Sprintf("must be at least %v characters long", schema.MinLength)
+			if schema.MinLength == 1 {
+				message = "must not be empty"
+			}
+
+			errs = append(errs, FieldError{Field: field, Message: message})
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range value {
+				errs = document.validate(schema.Items, item, fmt.Sprintf("%v[%v]", field, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, FieldError{Field: joinField(field, name), Message: "is required"})
+			}
+		}
+
+		// The properties are checked in a stable order, so that the errors are always reported in the same order.
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				propertySchema = schema.AdditionalProperties
+			}
+
+			if propertySchema != nil {
+				errs = document.validate(propertySchema, value[name], joinField(field, name), errs)
+			}
+		}
+	}
+
+	return errs
+}
+
+func checkType(t string, value interface{}) string {
+	ok := true
+
+	switch t {
+	case "string":
+		_, ok = value.(string)
+	case "boolean":
+		_, ok = value.(bool)
+	case "number":
+		_, ok = value.(float64)
+	case "integer":
+		number, isNumber := value.(float64)
+		ok = isNumber && number == math.Trunc(number)
+	case "array":
+		_, ok = value.([]interface{})
+	case "object":
+		_, ok = value.(map[string]interface{})
+	}
+
+	if ok {
+		return ""
+	}
+
+	if t == "array" || t == "object" || t == "integer" {
+		return "must be an " + t
+	}
+
+	return "must be a " + t
+}
+
+// inEnum compares the values by their JSON encoding, so that e.g. the integer 1 of a schema matches the 1.0 which
+// JSON numbers are decoded to.
+func inEnum(enum []interface{}, value interface{}) bool {
+	encodedValue, _ := json.Marshal(value)
+
+	for _, enumValue := range enum {
+		if encodedEnumValue, _ := json.Marshal(enumValue); bytes.Equal(encodedEnumValue, encodedValue) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatEnum(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, value := range enum {
+		values[i] = fmt.Sprint(value)
+	}
+
+	return strings.Join(values, ", ")
+}
+
+func joinField(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
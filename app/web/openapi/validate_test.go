@@ -0,0 +1,139 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	document := &Document{
+		Components: Components{
+			Schemas: map[string]*Schema{
+				"Type": {Type: "string", Enum: []interface{}{"source", "sink"}},
+				"Request": {
+					Type:     "object",
+					Required: []string{"type", "volume"},
+					Properties: map[string]*Schema{
+						"type":   Ref("Type"),
+						"index":  {Type: "integer", Minimum: Float(0)},
+						"name":   {Type: "string", MinLength: 1},
+						"label":  {Type: "string", MinLength: 3, Nullable: true},
+						"volume": {Type: "number", Minimum: Float(0), Maximum: Float(150)},
+						"muted":  {Type: "boolean"},
+						"tags":   {Type: "array", Items: &Schema{Type: "string", MinLength: 1}},
+						"level":  {Type: "integer", Enum: []interface{}{1, 2}},
+						"card":   {Type: "object", Properties: map[string]*Schema{"index": {Type: "integer"}}},
+						"broken": Ref("Missing"),
+					},
+				},
+				"Command": {
+					AllOf: []*Schema{
+						Ref("Request"),
+						{
+							Type:       "object",
+							Required:   []string{"op"},
+							Properties: map[string]*Schema{"op": {Type: "string"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		schema string
+		data   string
+		want   []FieldError
+	}{
+		{"valid", "Request", `{"type":"sink","volume":50,"level":2,"label":null,"tags":["a"]}`, nil},
+		{"invalid JSON", "Request", `{"type":`, []FieldError{{Message: "must be valid JSON"}}},
+		{"not an object", "Request", `[]`, []FieldError{{Message: "must be an object"}}},
+		{"null", "Request", `null`, []FieldError{{Message: "must not be null"}}},
+		{
+			"missing fields",
+			"Request",
+			`{}`,
+			[]FieldError{{Field: "type", Message: "is required"}, {Field: "volume", Message: "is required"}},
+		},
+		{
+			"wrong types",
+			"Request",
+			`{"type":"sink","volume":"50","muted":1,"index":1.5}`,
+			[]FieldError{
+				{Field: "index", Message: "must be an integer"},
+				{Field: "muted", Message: "must be a boolean"},
+				{Field: "volume", Message: "must be a number"},
+			},
+		},
+		{
+			"out of bounds",
+			"Request",
+			`{"type":"sink","volume":151,"index":-1}`,
+			[]FieldError{
+				{Field: "index", Message: "must be at least 0"},
+				{Field: "volume", Message: "must be at most 150"},
+			},
+		},
+		{
+			"not in enum",
+			"Request",
+			`{"type":"card","volume":1,"level":3}`,
+			[]FieldError{
+				{Field: "level", Message: "must be one of: 1, 2"},
+				{Field: "type", Message: "must be one of: source, sink"},
+			},
+		},
+		{
+			"too short",
+			"Request",
+			`{"type":"sink","volume":1,"name":"","label":"ab"}`,
+			[]FieldError{
+				{Field: "label", Message: "must be at least 3 characters long"},
+				{Field: "name", Message: "must not be empty"},
+			},
+		},
+		{
+			"nested fields",
+			"Request",
+			`{"type":"sink","volume":1,"tags":["a",""],"card":{"index":"1"}}`,
+			[]FieldError{
+				{Field: "card.index", Message: "must be an integer"},
+				{Field: "tags[1]", Message: "must not be empty"},
+			},
+		},
+		{
+			"unknown schema",
+			"Request",
+			`{"type":"sink","volume":1,"broken":1}`,
+			[]FieldError{{Field: "broken", Message: "refers to unknown schema Missing"}},
+		},
+		{
+			"all of",
+			"Command",
+			`{"type":"sink"}`,
+			[]FieldError{{Field: "volume", Message: "is required"}, {Field: "op", Message: "is required"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := document.Validate(Ref(test.schema), []byte(test.data))
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Validate(%v) = %v, want %v", test.data, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := &ValidationError{
+		Request: "volume",
+		Errors:  []FieldError{{Message: "must be an object"}, {Field: "volume", Message: "is required"}},
+	}
+
+	if want := "bad volume request: must be an object; volume: is required"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/scene"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/web"
+	"github.com/sadesyllas/go-cctl/app/web/openapi"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const apiV1Path = "/api/v1"
+
+// registerAPIRoutes registers the resource routes of the versioned API, where the sources, sinks and cards are
+// identified in the path, by anything that audio.CardsWithDevices.FindCardDevice and FindCard resolve.
+func registerAPIRoutes(router fiber.Router) {
+	for _, t := range []carddevice.CardDeviceType{carddevice.Source, carddevice.Sink} {
+		collectionPath := "/" + t.String() + "s"
+
+		router.Get(collectionPath, handleCardDevicesRequest(t))
+		router.Get(collectionPath+"/:id", handleCardDeviceRequest(t))
+		router.Patch(collectionPath+"/:id", handleCardDevicePatchRequest(t))
+		router.Post(collectionPath+"/:id/volume/step", handleCardDeviceStepRequest(t))
+		router.Post(collectionPath+"/:id/mute/toggle", handleCardDeviceMuteToggleRequest(t))
+		router.Get("/defaults/"+t.String(), handleDefaultCardDeviceGetRequest(t))
+		router.Put("/defaults/"+t.String(), handleDefaultCardDevicePutRequest(t))
+	}
+
+	router.Get("/cards", handleCardsRequest)
+	router.Get("/cards/:id", handleCardRequest)
+	router.Put("/cards/:id/profile", handleCardProfilePutRequest)
+	router.Get("/streams", handleStreamsRequest)
+	router.Get("/scenes", handleScenesRequest)
+	router.Post("/scenes/:name/apply", handleSceneApplyRequest)
+}
+
+// handleDeprecated marks the responses of a route which has been superseded by a route of the versioned API,
+// pointing to the API document, which names its successor.
+func handleDeprecated(c *fiber.Ctx) error {
+	c.Set("Deprecation", "true")
+	c.Set(fiber.HeaderLink, fmt.Sprintf(`<%v>; rel="deprecation"`, openAPIPath))
+
+	return c.Next()
+}
+
+func handleCardDevicesRequest(t carddevice.CardDeviceType) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := routeSpan(c)
+		defer span.End()
+
+		cardDevices, ok := audio.FetchCardDevices(t, ctx)
+		if !ok {
+			return fiber.NewError(fiber.StatusServiceUnavailable, fmt.Sprintf("could not fetch the %vs", t))
+		}
+
+		return sendJSON(c, cardDevices)
+	}
+}
+
+func handleCardDeviceRequest(t carddevice.CardDeviceType) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := routeSpan(c)
+		defer span.End()
+
+		cardDevice, err := findCardDevice(t, 0, pathID(c), true, ctx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+
+		return sendJSON(c, cardDevice)
+	}
+}
+
+func handleCardDevicePatchRequest(t carddevice.CardDeviceType) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := routeSpan(c)
+		defer span.End()
+
+		var patchRequest web.CardDevicePatchRequest
+		if err := decodeRequest(c.Body(), "card device patch", "CardDevicePatchRequest", &patchRequest); err != nil {
+			c.SendStatus(400)
+
+			return err
+		}
+
+		if patchRequest.Volume == nil && patchRequest.Muted == nil {
+			c.SendStatus(400)
+
+			return &openapi.ValidationError{
+				Request: "card device patch",
+				Errors:  []openapi.FieldError{{Message: "must set volume, muted or both"}},
+			}
+		}
+
+		id := pathID(c)
+		if _, err := findCardDevice(t, 0, id, false, ctx); err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+
+		// The card device is identified by the id of the path, rather than by the index it has been found with,
+		// which the commands resolve afresh, if it turns out to be stale.
+		if patchRequest.Volume != nil {
+			volumeRequest := web.VolumeRequest{Type: t.String(), Name: id, Volume: *patchRequest.Volume}
+			if err := setVolume(volumeRequest, ctx); err != nil {
+				c.SendStatus(400)
+
+				return err
+			}
+		}
+
+		if patchRequest.Muted != nil {
+			muteRequest := web.MuteRequest{Type: t.String(), Name: id, Mute: *patchRequest.Muted}
+			if err := setMute(muteRequest, ctx); err != nil {
+				c.SendStatus(400)
+
+				return err
+			}
+		}
+
+		return sendCardDevice(c, t, id, ctx)
+	}
+}
+
+func handleCardDeviceStepRequest(t carddevice.CardDeviceType) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := routeSpan(c)
+		defer span.End()
+
+		var stepRequest web.StepRequest
+		if err := decodeRequest(c.Body(), "volume step", "StepRequest", &stepRequest); err != nil {
+			c.SendStatus(400)
+
+			return err
+		}
+
+		id := pathID(c)
+		if _, err := findCardDevice(t, 0, id, false, ctx); err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+
+		volumeStepRequest := web.VolumeStepRequest{Type: t.String(), Name: id, Step: stepRequest.Step}
+		if err := stepVolume(volumeStepRequest, ctx); err != nil {
+			c.SendStatus(400)
+
+			return err
+		}
+
+		return sendCardDevice(c, t, id, ctx)
+	}
+}
+
+func handleCardDeviceMuteToggleRequest(t carddevice.CardDeviceType) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := routeSpan(c)
+		defer span.End()
+
+		id := pathID(c)
+		if _, err := findCardDevice(t, 0, id, false, ctx); err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+
+		if err := toggleMute(web.MuteToggleRequest{Type: t.String(), Name: id}, ctx); err != nil {
+			c.SendStatus(400)
+
+			return err
+		}
+
+		return sendCardDevice(c, t, id, ctx)
+	}
+}
+
+func handleDefaultCardDeviceGetRequest(t carddevice.CardDeviceType) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := routeSpan(c)
+		defer span.End()
+
+		return sendCardDevice(c, t, audio.DefaultAlias, ctx)
+	}
+}
+
+func handleDefaultCardDevicePutRequest(t carddevice.CardDeviceType) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := routeSpan(c)
+		defer span.End()
+
+		var reference web.CardDeviceReference
+		if err := decodeRequest(c.Body(), "default "+t.String(), "CardDeviceReference", &reference); err != nil {
+			c.SendStatus(400)
+
+			return err
+		}
+
+		defaultCardDeviceRequest := web.DefaultCardDeviceRequest{
+			Type:  t.String(),
+			Index: reference.Index,
+			Name:  reference.Name,
+		}
+		if err := setDefaultCardDevice(defaultCardDeviceRequest, ctx); err != nil {
+			c.SendStatus(400)
+
+			return err
+		}
+
+		return sendCardDevice(c, t, audio.DefaultAlias, ctx)
+	}
+}
+
+func handleCardsRequest(c *fiber.Ctx) error {
+	ctx, span := routeSpan(c)
+	defer span.End()
+
+	cards := audio.FetchCardsWithDevices(ctx).Cards
+	if cards == nil {
+		cards = []*card.Card{}
+	}
+
+	return sendJSON(c, cards)
+}
+
+func handleCardRequest(c *fiber.Ctx) error {
+	ctx, span := routeSpan(c)
+	defer span.End()
+
+	foundCard, err := audio.FetchCardsWithDevices(ctx).FindCard(pathID(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	return sendJSON(c, foundCard)
+}
+
+func handleCardProfilePutRequest(c *fiber.Ctx) error {
+	ctx, span := routeSpan(c)
+	defer span.End()
+
+	var profileRequest web.ProfileRequest
+	if err := decodeRequest(c.Body(), "card profile", "ProfileRequest", &profileRequest); err != nil {
+		c.SendStatus(400)
+
+		return err
+	}
+
+	// The card is resolved once, and set by its index, so that only the cards need to be read afresh to find it as
+	// changed.
+	cards, ok := audio.FetchCards(ctx)
+	if !ok {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "could not fetch the cards")
+	}
+
+	foundCard, err := (&audio.CardsWithDevices{Cards: cards}).FindCard(pathID(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	cardProfileRequest := web.CardProfileRequest{Index: foundCard.Index, Profile: profileRequest.Profile}
+	if err := setCardProfile(cardProfileRequest, ctx); err != nil {
+		c.SendStatus(400)
+
+		return err
+	}
+
+	cards, _ = audio.FetchCards(ctx)
+	updatedCard, err := (&audio.CardsWithDevices{Cards: cards}).FindCard(strconv.FormatUint(foundCard.Index, 10))
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	return sendJSON(c, updatedCard)
+}
+
+func handleStreamsRequest(c *fiber.Ctx) error {
+	ctx, span := routeSpan(c)
+	defer span.End()
+
+	sinkInputs, ok := audio.FetchAudioClients(carddevice.Sink, ctx)
+	if !ok {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "could not fetch the sink inputs")
+	}
+
+	sourceOutputs, ok := audio.FetchAudioClients(carddevice.Source, ctx)
+	if !ok {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "could not fetch the source outputs")
+	}
+
+	return sendJSON(c, web.StreamsResponse{SinkInputs: sinkInputs, SourceOutputs: sourceOutputs})
+}
+
+func handleScenesRequest(c *fiber.Ctx) error {
+	names := []string{}
+	for name := range config.Get().Scenes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return sendJSON(c, names)
+}
+
+func handleSceneApplyRequest(c *fiber.Ctx) error {
+	ctx, span := routeSpan(c)
+	defer span.End()
+
+	name := c.Params("name")
+	if unescapedName, err := url.PathUnescape(name); err == nil {
+		name = unescapedName
+	}
+
+	if !scene.Exists(name) {
+		return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("no such scene: %v", name))
+	}
+
+	cardsWithDevices, err := scene.Apply(name, eventBus, ctx)
+	if err != nil {
+		return commandError(err)
+	}
+
+	return sendJSON(c, web.NewCardsWithDevicesResponse(cardsWithDevices))
+}
+
+// sendCardDevice responds with the current state of the source or sink identified by id.
+func sendCardDevice(c *fiber.Ctx, t carddevice.CardDeviceType, id string, ctx context.Context) error {
+	cardDevice, err := findCardDevice(t, 0, id, true, ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	return sendJSON(c, cardDevice)
+}
+
+func sendJSON(c *fiber.Ctx, value interface{}) error {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.Context().SetContentType("application/json")
+
+	return c.Send(valueJson)
+}
+
+// pathID returns the id parameter of the path, which clients escape when it is e.g. a description pattern.
+func pathID(c *fiber.Ctx) string {
+	id := c.Params("id")
+	if unescapedID, err := url.PathUnescape(id); err == nil {
+		return unescapedID
+	}
+
+	return id
+}
+
+// routeSpan starts a span named after the method and the template of the route, e.g. "GET /api/v1/sinks/:id".
+func routeSpan(c *fiber.Ctx) (context.Context, trace.Span) {
+	return app.SpanWithContext(c.UserContext(), c.Route().Method+" "+c.Route().Path)
+}
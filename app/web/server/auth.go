@@ -0,0 +1,249 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/web"
+)
+
+// role is what a client is allowed to do, as granted by its credentials.
+type role uint64
+
+const (
+	// roleNone has not been authenticated yet, which only websockets may be left with, until their first message.
+	roleNone role = iota
+	// roleRead may only read the device state.
+	roleRead
+	// roleControl may also change the device state.
+	roleControl
+)
+
+// roleLocal is the key of the role of the client among the locals.
+const roleLocal = "role"
+
+// wsAuthWait is how long a websocket may take to authenticate with its first message.
+const wsAuthWait = 10 * time.Second
+
+func parseRole(value string) role {
+	switch value {
+	case "read":
+		return roleRead
+	case "control":
+		return roleControl
+	}
+
+	return roleNone
+}
+
+// handleAuth authenticates the client and checks that its role allows the request, i.e. read for GET and HEAD
+// requests and control for everything else.
+func handleAuth(c *fiber.Ctx) error {
+	// Preflight requests never carry credentials.
+	if c.Method() == fiber.MethodOptions {
+		return c.Next()
+	}
+
+	clientRole, err := authenticate(c)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer, Basic realm="go-cctl"`)
+
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if clientRole == roleNone {
+		// Websockets may still authenticate with their first message, since browsers cannot set their headers.
+		if !websocket.IsWebSocketUpgrade(c) {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer, Basic realm="go-cctl"`)
+
+			return fiber.NewError(fiber.StatusUnauthorized, "credentials are required from outside localhost and from web pages of other origins")
+		}
+	} else if clientRole < requiredRole(c.Method()) {
+		return fiber.NewError(fiber.StatusForbidden, "the control role is required")
+	}
+
+	c.Locals(roleLocal, clientRole)
+
+	return c.Next()
+}
+
+func requiredRole(method string) role {
+	if method == fiber.MethodGet || method == fiber.MethodHead {
+		return roleRead
+	}
+
+	return roleControl
+}
+
+// authenticate returns roleNone when the client has presented no credentials and is not on localhost, or is a web
+// page which is not trusted there, and an error when it has presented invalid ones.
+func authenticate(c *fiber.Ctx) (role, error) {
+	authorization := c.Get(fiber.HeaderAuthorization)
+
+	switch {
+	case strings.HasPrefix(authorization, "Bearer "):
+		if clientRole := tokenRole(strings.TrimPrefix(authorization, "Bearer ")); clientRole != roleNone {
+			return clientRole, nil
+		}
+
+		return roleNone, fmt.Errorf("invalid token")
+	case strings.HasPrefix(authorization, "Basic "):
+		credentials, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "Basic "))
+		if err != nil {
+			return roleNone, fmt.Errorf("invalid basic credentials")
+		}
+
+		usernameAndPassword := strings.SplitN(string(credentials), ":", 2)
+		if len(usernameAndPassword) != 2 {
+			return roleNone, fmt.Errorf("invalid basic credentials")
+		}
+
+		if clientRole := userRole(usernameAndPassword[0], usernameAndPassword[1]); clientRole != roleNone {
+			return clientRole, nil
+		}
+
+		return roleNone, fmt.Errorf("invalid username or password")
+	case authorization != "":
+		return roleNone, fmt.Errorf("unsupported authorization scheme")
+	}
+
+	if token := c.Query("token"); token != "" && websocket.IsWebSocketUpgrade(c) {
+		if clientRole := tokenRole(token); clientRole != roleNone {
+			return clientRole, nil
+		}
+
+		return roleNone, fmt.Errorf("invalid token")
+	}
+
+	if isLocal(c) && trustedOrigin(c) {
+		return roleControl, nil
+	}
+
+	return roleNone, nil
+}
+
+// trustedOrigin reports whether a request from localhost may be trusted without credentials, since any web page
+// which the user visits may send requests to localhost too.
+//
+// That is when it comes from no web page at all, from a page of the server itself on a loopback host, which a page
+// of another origin cannot pose as by rebinding its DNS name, or from a page of an origin which is allowed by
+// name, i.e. not only through "*".
+func trustedOrigin(c *fiber.Ctx) bool {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+
+	if sameOrigin(c, origin) && isLoopbackHost(c.Hostname()) {
+		return true
+	}
+
+	for _, pattern := range config.Get().Server.CORS.AllowedOrigins {
+		if pattern == "*" {
+			continue
+		}
+
+		if matched, _ := filepath.Match(pattern, origin); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isLoopbackHost reports whether host, with or without a port, is localhost or a loopback address.
+func isLoopbackHost(host string) bool {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLocal reports whether the client is on localhost, i.e. connected through the loopback interface or a unix
+// socket.
+func isLocal(c *fiber.Ctx) bool {
+	if _, ok := c.Context().RemoteAddr().(*net.UnixAddr); ok {
+		return true
+	}
+
+	return c.Context().RemoteIP().IsLoopback()
+}
+
+func tokenRole(token string) role {
+	for _, tokenConfig := range config.Get().Auth.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(tokenConfig.Token)) == 1 {
+			return parseRole(tokenConfig.Role)
+		}
+	}
+
+	return roleNone
+}
+
+func userRole(username string, password string) role {
+	for _, userConfig := range config.Get().Auth.Users {
+		usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(userConfig.Username)) == 1
+		passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(userConfig.Password)) == 1
+
+		if usernameMatches && passwordMatches {
+			return parseRole(userConfig.Role)
+		}
+	}
+
+	return roleNone
+}
+
+// websocketRole returns the role granted to the websocket upgrade request.
+func websocketRole(locals func(string) interface{}) role {
+	if clientRole, ok := locals(roleLocal).(role); ok {
+		return clientRole
+	}
+
+	return roleNone
+}
+
+// authenticateWebsocket expects the first message of a websocket to be {"op":"auth","id":"...","token":"..."},
+// which is acknowledged like a command.
+func authenticateWebsocket(c *websocket.Conn, inbound <-chan []byte) (role, error) {
+	timeout := time.NewTimer(wsAuthWait)
+	defer timeout.Stop()
+
+	select {
+	case data, ok := <-inbound:
+		if !ok {
+			return roleNone, fmt.Errorf("the websocket has been closed")
+		}
+
+		var request web.WebsocketRequest
+		if err := json.Unmarshal(data, &request); err != nil || request.Op != "auth" {
+			return roleNone, fmt.Errorf("the first message must authenticate")
+		}
+
+		clientRole := tokenRole(request.Token)
+		if clientRole == roleNone {
+			writeWebsocket(c, web.NewCommandResponse(request.ID, fmt.Errorf("invalid token")))
+
+			return roleNone, fmt.Errorf("invalid token")
+		}
+
+		return clientRole, writeWebsocket(c, web.NewCommandResponse(request.ID, nil))
+	case <-timeout.C:
+		return roleNone, fmt.Errorf("authentication has timed out")
+	}
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+	"github.com/sadesyllas/go-cctl/app/web"
+)
+
+// The commands below are shared by the REST handlers and the websocket command channel. They return an error when
+// the request is invalid, in which case nothing has been changed, or a fiber.Error with status 503 when pacmd has
+// failed to carry it out.
+
+func setVolume(volumeRequest web.VolumeRequest, ctx context.Context) error {
+	cardDeviceType, err := carddevice.ParseableCardDeviceType(volumeRequest.Type).Parse()
+	if err != nil {
+		return fmt.Errorf("bad volume request: invalid card device type")
+	}
+
+	if math.IsNaN(volumeRequest.Volume) || volumeRequest.Volume < 0 {
+		return fmt.Errorf("bad volume request: invalid volume")
+	}
+
+	// Volume requests arrive in bursts while a slider is dragged, so they are coalesced and the device state
+	// emission that follows them is throttled.
+	setCardDeviceVolume := func(cardDevice *carddevice.CardDevice) error {
+		return volumes.set(cardDeviceType, cardDevice.Index, volumeRequest.Volume, ctx)
+	}
+
+	cardDevice, err := findCardDevice(cardDeviceType, volumeRequest.Index, volumeRequest.Name, false, ctx)
+	if err != nil {
+		return fmt.Errorf("bad volume request: %v", err)
+	}
+
+	return commandError(retryStale(cardDeviceType, cardDevice, volumeRequest.Name, setCardDeviceVolume, ctx))
+}
+
+func setMute(muteRequest web.MuteRequest, ctx context.Context) error {
+	cardDeviceType, err := carddevice.ParseableCardDeviceType(muteRequest.Type).Parse()
+	if err != nil {
+		return fmt.Errorf("bad mute request: invalid card device type")
+	}
+
+	setCardDeviceMute := func(cardDevice *carddevice.CardDevice) error {
+		return audio.ToggleMute(cardDeviceType, cardDevice.Index, muteRequest.Mute, ctx)
+	}
+
+	cardDevice, err := findCardDevice(cardDeviceType, muteRequest.Index, muteRequest.Name, false, ctx)
+	if err != nil {
+		return fmt.Errorf("bad mute request: %v", err)
+	}
+
+	err = retryStale(cardDeviceType, cardDevice, muteRequest.Name, setCardDeviceMute, ctx)
+
+	emitDeviceState(ctx)
+
+	return commandError(err)
+}
+
+func stepVolume(volumeStepRequest web.VolumeStepRequest, ctx context.Context) error {
+	cardDeviceType, err := carddevice.ParseableCardDeviceType(volumeStepRequest.Type).Parse()
+	if err != nil {
+		return fmt.Errorf("bad volume step request: invalid card device type")
+	}
+
+	if math.IsNaN(volumeStepRequest.Step) || math.IsInf(volumeStepRequest.Step, 0) {
+		return fmt.Errorf("bad volume step request: invalid step")
+	}
+
+	cardDevice, err := findCardDevice(cardDeviceType, volumeStepRequest.Index, volumeStepRequest.Name, false, ctx)
+	if err != nil {
+		return fmt.Errorf("bad volume step request: %v", err)
+	}
+
+	// Steps go through the volume coalescer, like the volumes, which reads the volume they are added to only when
+	// the writes before them have been carried out, so that quick steps add up instead of overwriting each other.
+	return commandError(volumes.step(cardDeviceType, cardDevice.Index, volumeStepRequest.Step, ctx))
+}
+
+func toggleMute(muteToggleRequest web.MuteToggleRequest, ctx context.Context) error {
+	cardDeviceType, err := carddevice.ParseableCardDeviceType(muteToggleRequest.Type).Parse()
+	if err != nil {
+		return fmt.Errorf("bad mute toggle request: invalid card device type")
+	}
+
+	cardDevice, err := findCardDevice(cardDeviceType, muteToggleRequest.Index, muteToggleRequest.Name, false, ctx)
+	if err != nil {
+		return fmt.Errorf("bad mute toggle request: %v", err)
+	}
+
+	// The mute status is read afresh while no other change is written to the card device, so that quick toggles
+	// do not read the same status and cancel each other out.
+	err = volumes.serialize(cardDeviceType, cardDevice.Index, func() error {
+		freshCardDevice, err := findCardDevice(cardDeviceType, cardDevice.Index, "", true, ctx)
+		if err != nil {
+			return err
+		}
+
+		return audio.ToggleMute(cardDeviceType, freshCardDevice.Index, !freshCardDevice.IsMuted, ctx)
+	})
+
+	deviceStateEmission.trigger(ctx)
+
+	return commandError(err)
+}
+
+func setDefaultCardDevice(defaultCardDeviceRequest web.DefaultCardDeviceRequest, ctx context.Context) error {
+	cardDeviceType, err := carddevice.ParseableCardDeviceType(defaultCardDeviceRequest.Type).Parse()
+	if err != nil {
+		return fmt.Errorf("bad default card device request: invalid card device type")
+	}
+
+	cardDevice, err := findCardDevice(
+		cardDeviceType, defaultCardDeviceRequest.Index, defaultCardDeviceRequest.Name, false, ctx)
+	if err != nil {
+		return fmt.Errorf("bad default card device request: %v", err)
+	}
+
+	err = retryStale(cardDeviceType, cardDevice, defaultCardDeviceRequest.Name,
+		func(cardDevice *carddevice.CardDevice) error {
+			if err := audio.SetDefaultCardDevice(cardDeviceType, cardDevice.Index, ctx); err != nil {
+				return err
+			}
+
+			movedAudioClients := audio.MoveAudioClients(cardDeviceType, cardDevice.Index, cardDevice.Name, ctx)
+
+			for _, audioClient := range movedAudioClients {
+				event.StreamMovedTopic.Publish(eventBus, event.NewStreamMoved(
+					cardDeviceType, audioClient, cardDevice.Index, cardDevice.Name), ctx)
+			}
+
+			return nil
+		}, ctx)
+
+	emitDeviceState(ctx)
+
+	return commandError(err)
+}
+
+func setCardProfile(cardProfileRequest web.CardProfileRequest, ctx context.Context) error {
+	if cardProfileRequest.Profile < card.HeadsetHeadUnit || cardProfileRequest.Profile > card.Off {
+		return fmt.Errorf("bad card profile request: invalid card profile")
+	}
+
+	if cardProfileRequest.Name == "" {
+		err := audio.SetCardProfile(cardProfileRequest.Index, cardProfileRequest.Profile, ctx)
+
+		emitDeviceState(ctx)
+
+		return commandError(err)
+	}
+
+	staleCard, err := latestDeviceState.get().FindCard(cardProfileRequest.Name)
+	if err == nil {
+		if err = audio.SetCardProfile(staleCard.Index, cardProfileRequest.Profile, ctx); err == nil {
+			emitDeviceState(ctx)
+
+			return nil
+		}
+	}
+
+	// The card is resolved afresh, when it is missing from the latest device state or when setting its profile
+	// has failed, since the latest device state may be stale, as with retryStale.
+	c, findErr := latestDeviceState.refresh(ctx).FindCard(cardProfileRequest.Name)
+	if findErr != nil {
+		if staleCard != nil {
+			return commandError(err)
+		}
+
+		return fmt.Errorf("bad card profile request: %v", findErr)
+	}
+
+	if staleCard != nil && staleCard.Index == c.Index {
+		return commandError(err)
+	}
+
+	err = audio.SetCardProfile(c.Index, cardProfileRequest.Profile, ctx)
+
+	emitDeviceState(ctx)
+
+	return commandError(err)
+}
+
+// findCardDevice resolves the card device identified by name, or by index when name is empty.
+//
+// Unless fresh is set, it first looks the card device up in the latest device state, which is enough to resolve
+// an identifier but may hold a stale volume or mute status.
+func findCardDevice(
+	t carddevice.CardDeviceType,
+	index uint64,
+	name string,
+	fresh bool,
+	ctx context.Context) (*carddevice.CardDevice, error) {
+	id := name
+	if id == "" {
+		id = strconv.FormatUint(index, 10)
+	}
+
+	if !fresh {
+		if cardDevice, err := latestDeviceState.get().FindCardDevice(t, id); err == nil {
+			return cardDevice, nil
+		}
+	}
+
+	cardDevices, ok := audio.FetchCardDevices(t, ctx)
+	if !ok {
+		return nil, fmt.Errorf("could not fetch the %vs", t)
+	}
+
+	cardsWithDevices := new(audio.CardsWithDevices)
+	if t == carddevice.Source {
+		cardsWithDevices.Sources = cardDevices
+	} else {
+		cardsWithDevices.Sinks = cardDevices
+	}
+
+	return cardsWithDevices.FindCardDevice(t, id)
+}
+
+// retryStale runs command on a card device which has been resolved by name against the latest device state and,
+// when it fails, resolves the name afresh and runs command once more, if the name now refers to another card
+// device, e.g. to a Bluetooth device which has reconnected under a new index. A card device which has been resolved
+// by index, i.e. with an empty name, is not retried.
+func retryStale(
+	t carddevice.CardDeviceType,
+	cardDevice *carddevice.CardDevice,
+	name string,
+	command func(*carddevice.CardDevice) error,
+	ctx context.Context) error {
+	err := command(cardDevice)
+	if err == nil {
+		return nil
+	}
+
+	freshCardDevice, findErr := latestDeviceState.refresh(ctx).FindCardDevice(t, name)
+	if findErr != nil || freshCardDevice.Index == cardDevice.Index {
+		return err
+	}
+
+	return command(freshCardDevice)
+}
+
+// commandError reports that pacmd has failed to carry out a valid request.
+func commandError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+}
+
+// deviceStateCache keeps the latest published device state, against which device identifiers are resolved.
+type deviceStateCache struct {
+	lock  sync.RWMutex
+	state *audio.CardsWithDevices
+}
+
+var latestDeviceState = &deviceStateCache{state: new(audio.CardsWithDevices)}
+
+func (cache *deviceStateCache) get() *audio.CardsWithDevices {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	return cache.state
+}
+
+func (cache *deviceStateCache) set(cardsWithDevices *audio.CardsWithDevices) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.state = cardsWithDevices
+}
+
+// refresh publishes the current device state, which also updates the cache, and returns it.
+func (cache *deviceStateCache) refresh(ctx context.Context) *audio.CardsWithDevices {
+	cardsWithDevices := emitDeviceState(ctx)
+
+	cache.set(cardsWithDevices)
+
+	return cardsWithDevices
+}
+
+func trackDeviceState(bus *pubsub.Bus) {
+	subscription := event.DeviceStateTopic.Subscribe(bus, "device_state_cache", pubsub.DropOldest, 1)
+	defer subscription.Close()
+
+	for msg := range subscription.C() {
+		if payload, ok := event.DeviceStateTopic.Payload(msg); ok {
+			latestDeviceState.set(payload)
+		}
+	}
+}
+
+// emitDeviceState publishes the current device state as part of the trace of ctx, so that whatever the change
+// sets off, e.g. the watchdog moving streams, is traced along with the request that made it.
+func emitDeviceState(ctx context.Context) *audio.CardsWithDevices {
+	cardsWithDevices := audio.FetchCardsWithDevices(ctx)
+
+	event.DeviceStateTopic.Publish(eventBus, cardsWithDevices, ctx)
+
+	return cardsWithDevices
+}
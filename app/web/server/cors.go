@@ -0,0 +1,68 @@
+package server
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sadesyllas/go-cctl/app/config"
+)
+
+// corsMaxAge is how long browsers may cache the response to a preflight request.
+const corsMaxAge = 10 * 60
+
+// handleCORS applies the CORS policy of the configuration and answers preflight requests.
+//
+// Unlike browsers, which only hide the responses from pages of other origins, it rejects their requests, since e.g.
+// a plain form submission is enough to change the device state. The pages of the server itself, i.e. the embedded
+// web UI, are always allowed, since browsers send their origin too, e.g. on POST requests and websockets.
+func handleCORS(c *fiber.Ctx) error {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" || sameOrigin(c, origin) {
+		return c.Next()
+	}
+
+	corsConfig := config.Get().Server.CORS
+
+	if !originAllowed(origin, corsConfig.AllowedOrigins) {
+		return fiber.NewError(fiber.StatusForbidden, "origin not allowed")
+	}
+
+	c.Vary(fiber.HeaderOrigin)
+	c.Set(fiber.HeaderAccessControlAllowOrigin, origin)
+
+	if c.Method() != fiber.MethodOptions || c.Get(fiber.HeaderAccessControlRequestMethod) == "" {
+		return c.Next()
+	}
+
+	c.Set(fiber.HeaderAccessControlAllowMethods, strings.Join(corsConfig.AllowedMethods, ", "))
+	c.Set(fiber.HeaderAccessControlAllowHeaders, strings.Join(corsConfig.AllowedHeaders, ", "))
+	c.Set(fiber.HeaderAccessControlMaxAge, strconv.Itoa(corsMaxAge))
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func originAllowed(origin string, allowedOrigins []string) bool {
+	for _, pattern := range allowedOrigins {
+		if pattern == "*" {
+			return true
+		}
+
+		if matched, _ := filepath.Match(pattern, origin); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameOrigin reports whether origin is the scheme and host which the request has been sent to.
+func sameOrigin(c *fiber.Ctx, origin string) bool {
+	scheme := "http"
+	if conn, ok := c.Context().Conn().(*sniffConn); ok && conn.isTLS() {
+		scheme = "https"
+	}
+
+	return strings.EqualFold(origin, scheme+"://"+c.Hostname())
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sadesyllas/go-cctl/app/config"
+)
+
+// listen listens on a unix socket when the address contains a slash, or on a TCP host:port otherwise, which
+// serves TLS too when it is enabled.
+func listen(address string) (net.Listener, error) {
+	serverConfig := config.Get().Server
+
+	if !strings.Contains(address, "/") {
+		listener, err := net.Listen("tcp", address)
+		if err != nil || !serverConfig.TLS.Enabled() {
+			return listener, err
+		}
+
+		tlsConfig, err := newTLSConfig(serverConfig.TLS)
+		if err != nil {
+			listener.Close()
+
+			return nil, err
+		}
+
+		return &sniffListener{Listener: listener, tlsConfig: tlsConfig}, nil
+	}
+
+	if err := removeStaleSocket(address); err != nil {
+		return nil, err
+	}
+
+	// Connecting to the socket requires write permission, so its mode decides who may use it, while clients
+	// connecting through it are trusted like those on localhost. The mode is set before any connection is
+	// accepted, rather than through the umask, which would apply to the files of the whole process.
+	mode, _ := strconv.ParseUint(serverConfig.SocketMode, 8, 32)
+
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(address, os.FileMode(mode)); err != nil {
+		listener.Close()
+
+		return nil, fmt.Errorf("could not set the mode of %v: %v", address, err)
+	}
+
+	return listener, nil
+}
+
+// removeStaleSocket removes a socket left behind by a go-cctl which has crashed, which would make listening fail.
+//
+// Anything else at the address is left alone, i.e. a file which is not a socket or the socket of a go-cctl which
+// is still running, which listening then fails on.
+func removeStaleSocket(address string) error {
+	fileInfo, err := os.Lstat(address)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if fileInfo.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%v exists and is not a socket", address)
+	}
+
+	conn, err := net.DialTimeout("unix", address, time.Second)
+	if err == nil {
+		conn.Close()
+
+		return fmt.Errorf("%v is in use by another process", address)
+	}
+
+	return os.Remove(address)
+}
+
+// mergedListener accepts the connections of several listeners, so that a single server can serve them all.
+type mergedListener struct {
+	listeners []net.Listener
+	conns     chan net.Conn
+	errs      chan error
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func mergeListeners(listeners ...net.Listener) net.Listener {
+	if len(listeners) == 1 {
+		return listeners[0]
+	}
+
+	merged := &mergedListener{
+		listeners: listeners,
+		conns:     make(chan net.Conn),
+		errs:      make(chan error),
+		done:      make(chan struct{}),
+	}
+
+	for _, listener := range listeners {
+		go merged.accept(listener)
+	}
+
+	return merged
+}
+
+func (merged *mergedListener) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case merged.errs <- err:
+			case <-merged.done:
+			}
+
+			return
+		}
+
+		select {
+		case merged.conns <- conn:
+		case <-merged.done:
+			conn.Close()
+
+			return
+		}
+	}
+}
+
+func (merged *mergedListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-merged.conns:
+		return conn, nil
+	case err := <-merged.errs:
+		return nil, err
+	case <-merged.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (merged *mergedListener) Close() error {
+	merged.closeOnce.Do(func() { close(merged.done) })
+
+	var err error
+	for _, listener := range merged.listeners {
+		if closeErr := listener.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+func (merged *mergedListener) Addr() net.Addr {
+	return merged.listeners[0].Addr()
+}
@@ -0,0 +1,829 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sadesyllas/go-cctl/app/web/openapi"
+)
+
+const openAPIPath = "/openapi.json"
+
+// apiDocument describes every route of the server, as well as the messages of the websocket, and is also what the
+// request bodies are validated against.
+var apiDocument = newAPIDocument()
+
+var apiDocumentJson, _ = json.Marshal(apiDocument)
+
+func handleOpenAPIRequest(c *fiber.Ctx) error {
+	c.Context().SetContentType("application/json")
+
+	return c.Send(apiDocumentJson)
+}
+
+// decodeRequest validates data against the schema of the components with the given name, before decoding it
+// into request. name names the request in the error, e.g. "volume" for "bad volume request: ...".
+func decodeRequest(data []byte, name string, schemaName string, request interface{}) error {
+	if fieldErrors := apiDocument.Validate(openapi.Ref(schemaName), data); len(fieldErrors) != 0 {
+		return &openapi.ValidationError{Request: name, Errors: fieldErrors}
+	}
+
+	if err := json.Unmarshal(data, request); err != nil {
+		return fmt.Errorf("bad %v request", name)
+	}
+
+	return nil
+}
+
+func newAPIDocument() *openapi.Document {
+	ok := &openapi.Response{Description: "The request has been carried out."}
+	badRequest := &openapi.Response{
+		Description: "The request is invalid, e.g. it names no known device, or its body does not match its schema.",
+		Content:     openapi.JSON(openapi.Ref("Error")),
+	}
+	unauthorized := &openapi.Response{Description: "Credentials are required from outside localhost and from web pages of other origins."}
+	forbidden := &openapi.Response{Description: "The credentials do not grant the required role."}
+	deviceState := openapi.Ref("CardsWithDevicesResponse")
+	text := func(schema *openapi.Schema) map[string]*openapi.MediaType {
+		return map[string]*openapi.MediaType{"text/plain": {Schema: schema}}
+	}
+	failed := &openapi.Response{
+		Description: "The sound server has failed to carry out the request.",
+		Content:     text(&openapi.Schema{Type: "string"}),
+	}
+
+	command := func(operationID string, summary string, schemaName string) map[string]*openapi.Operation {
+		return map[string]*openapi.Operation{
+			"post": {
+				OperationID: operationID,
+				Summary:     summary,
+				RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref(schemaName))},
+				Responses: map[string]*openapi.Response{
+					"200": ok,
+					"400": badRequest,
+					"401": unauthorized,
+					"403": forbidden,
+					"503": failed,
+				},
+			},
+		}
+	}
+
+	document := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title: "go-cctl",
+			Description: "Controls the sound cards and devices of PulseAudio, and pushes their state to its clients. " +
+				"Clients outside localhost, and web pages of other origins, must authenticate, with the read role for " +
+				"GET requests and the control role for the rest.",
+			Version: "1.0.0",
+		},
+		Security: []map[string][]string{{"bearer": {}}, {"basic": {}}},
+		Paths: map[string]map[string]*openapi.Operation{
+			openAPIPath: {
+				"get": {
+					OperationID: "getOpenAPI",
+					Summary:     "This document, which needs no credentials",
+					Responses: map[string]*openapi.Response{
+						"200": {Description: "This document.", Content: openapi.JSON(&openapi.Schema{Type: "object"})},
+					},
+				},
+			},
+			uiPath + "/{path}": {
+				"get": {
+					OperationID: "getUI",
+					Summary:     "The web UI, which needs no credentials",
+					Parameters: []*openapi.Parameter{
+						{Name: "path", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}},
+					},
+					Responses: map[string]*openapi.Response{
+						"200": {Description: "A file of the web UI, or its index.html for the paths it routes itself."},
+						"404": {Description: "No such file."},
+					},
+				},
+			},
+			metricsPath: {
+				"get": {
+					OperationID: "getMetrics",
+					Summary:     "Prometheus metrics",
+					Responses: map[string]*openapi.Response{
+						"200": {
+							Description: "The metrics, in the Prometheus text format.",
+							Content:     text(&openapi.Schema{Type: "string"}),
+						},
+						"401": unauthorized,
+						"403": forbidden,
+					},
+				},
+			},
+			"/debug/loglevel": {
+				"get": {
+					OperationID: "getLogLevel",
+					Summary:     "The log level",
+					Responses: map[string]*openapi.Response{
+						"200": {Description: "The log level.", Content: openapi.JSON(openapi.Ref("LogLevel"))},
+						"401": unauthorized,
+						"403": forbidden,
+					},
+				},
+				"put": {
+					OperationID: "setLogLevel",
+					Summary:     "Changes the log level until go-cctl exits",
+					RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("LogLevel"))},
+					Responses: map[string]*openapi.Response{
+						"200": {Description: "The new log level.", Content: openapi.JSON(openapi.Ref("LogLevel"))},
+						"400": {Description: "The log level is invalid."},
+						"401": unauthorized,
+						"403": forbidden,
+					},
+				},
+			},
+			"/audio": {
+				"get": {
+					OperationID: "getDeviceState",
+					Summary:     "The cards, sources and sinks",
+					Responses: map[string]*openapi.Response{
+						"200": {Description: "The device state.", Content: openapi.JSON(deviceState)},
+						"401": unauthorized,
+						"403": forbidden,
+					},
+				},
+			},
+			"/audio/ws": {
+				"get": {
+					OperationID: "openWebsocket",
+					Summary:     "A websocket which follows the device state and accepts commands",
+					Description: "Without topics, the server sends a StateSync snapshot, followed by a StateSync patch " +
+						"for every change. With topics, it sends an Event for every message of those topics. Either way, " +
+						"it answers every command with a CommandResponse. A client which has not authenticated " +
+						"otherwise must send an auth message first.",
+					Parameters: []*openapi.Parameter{
+						{
+							Name:        "topics",
+							In:          "query",
+							Description: "A comma separated list of the topics to follow.",
+							Schema:      openapi.Ref("Topic"),
+						},
+						{
+							Name:        "token",
+							In:          "query",
+							Description: "A token, since browsers cannot set the headers of a websocket.",
+							Schema:      &openapi.Schema{Type: "string"},
+						},
+					},
+					Responses: map[string]*openapi.Response{
+						"101": {Description: "The connection has been upgraded to a websocket."},
+						"401": unauthorized,
+					},
+					Websocket: &openapi.Websocket{
+						Client: openapi.Ref("WebsocketClientMessage"),
+						Server: openapi.Ref("WebsocketServerMessage"),
+					},
+				},
+			},
+			"/audio/events": {
+				"get": {
+					OperationID: "streamDeviceState",
+					Summary:     "A server-sent event stream of the device state",
+					Parameters: []*openapi.Parameter{
+						{
+							Name:        "Last-Event-ID",
+							In:          "header",
+							Description: "The id of the last event seen, to resume from it.",
+							Schema:      &openapi.Schema{Type: "integer", Minimum: openapi.Float(0)},
+						},
+					},
+					Responses: map[string]*openapi.Response{
+						"200": {
+							Description: "device_state events, each carrying a CardsWithDevicesResponse as its data.",
+							Content:     map[string]*openapi.MediaType{"text/event-stream": {Schema: deviceState}},
+						},
+						"401": unauthorized,
+						"403": forbidden,
+					},
+				},
+			},
+			"/audio/volume":      command("setVolume", "Sets the volume of a source or sink", "VolumeRequest"),
+			"/audio/volume/step": command("stepVolume", "Changes the volume of a source or sink", "VolumeStepRequest"),
+			"/audio/mute":        command("setMute", "Mutes or unmutes a source or sink", "MuteRequest"),
+			"/audio/mute/toggle": command("toggleMute", "Flips the mute status of a device", "MuteToggleRequest"),
+			"/audio/default":     command("setDefault", "Makes a device the default one", "DefaultCardDeviceRequest"),
+			"/audio/profile":     command("setProfile", "Sets the profile of a card", "CardProfileRequest"),
+		},
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+				"basic":  {Type: "http", Scheme: "basic"},
+			},
+			Schemas: newAPISchemas(),
+		},
+	}
+
+	successors := map[string]string{
+		"/audio/volume":      "PATCH " + apiV1Path + "/{sources,sinks}/{id}",
+		"/audio/volume/step": "POST " + apiV1Path + "/{sources,sinks}/{id}/volume/step",
+		"/audio/mute":        "PATCH " + apiV1Path + "/{sources,sinks}/{id}",
+		"/audio/mute/toggle": "POST " + apiV1Path + "/{sources,sinks}/{id}/mute/toggle",
+		"/audio/default":     "PUT " + apiV1Path + "/defaults/{source,sink}",
+		"/audio/profile":     "PUT " + apiV1Path + "/cards/{id}/profile",
+	}
+	for path, successor := range successors {
+		operation := document.Paths[path]["post"]
+		operation.Deprecated = true
+		operation.Description = "Superseded by " + successor + "."
+	}
+
+	notFound := &openapi.Response{Description: "No such resource.", Content: text(&openapi.Schema{Type: "string"})}
+	unavailable := &openapi.Response{
+		Description: "The sound server could not be reached.",
+		Content:     text(&openapi.Schema{Type: "string"}),
+	}
+	idParameter := &openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Required:    true,
+		Description: "An index, a name or a case insensitive description pattern, escaped as a path segment.",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+
+	for _, t := range []string{"source", "sink"} {
+		title := strings.Title(t)
+		device := openapi.JSON(openapi.Ref("CardDevice"))
+
+		document.Paths[apiV1Path+"/"+t+"s"] = map[string]*openapi.Operation{
+			"get": {
+				OperationID: "list" + title + "s",
+				Summary:     "The " + t + "s",
+				Responses: map[string]*openapi.Response{
+					"200": {
+						Description: "The " + t + "s.",
+						Content:     openapi.JSON(&openapi.Schema{Type: "array", Items: openapi.Ref("CardDevice")}),
+					},
+					"401": unauthorized,
+					"403": forbidden,
+					"503": unavailable,
+				},
+			},
+		}
+		document.Paths[apiV1Path+"/"+t+"s/{id}"] = map[string]*openapi.Operation{
+			"get": {
+				OperationID: "get" + title,
+				Summary:     "A " + t,
+				Parameters:  []*openapi.Parameter{idParameter},
+				Responses: map[string]*openapi.Response{
+					"200": {Description: "The " + t + ".", Content: device},
+					"401": unauthorized,
+					"403": forbidden,
+					"404": notFound,
+				},
+			},
+			"patch": {
+				OperationID: "patch" + title,
+				Summary:     "Changes the volume, the mute status, or both, of a " + t,
+				Parameters:  []*openapi.Parameter{idParameter},
+				RequestBody: &openapi.RequestBody{
+					Required: true,
+					Content:  openapi.JSON(openapi.Ref("CardDevicePatchRequest")),
+				},
+				Responses: map[string]*openapi.Response{
+					"200": {Description: "The " + t + ", as changed.", Content: device},
+					"400": badRequest,
+					"401": unauthorized,
+					"403": forbidden,
+					"404": notFound,
+					"503": failed,
+				},
+			},
+		}
+		document.Paths[apiV1Path+"/"+t+"s/{id}/volume/step"] = map[string]*openapi.Operation{
+			"post": {
+				OperationID: "step" + title + "Volume",
+				Summary:     "Changes the volume of a " + t + " by a number of percentage points",
+				Parameters:  []*openapi.Parameter{idParameter},
+				RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("StepRequest"))},
+				Responses: map[string]*openapi.Response{
+					"200": {Description: "The " + t + ", as changed.", Content: device},
+					"400": badRequest,
+					"401": unauthorized,
+					"403": forbidden,
+					"404": notFound,
+					"503": failed,
+				},
+			},
+		}
+		document.Paths[apiV1Path+"/"+t+"s/{id}/mute/toggle"] = map[string]*openapi.Operation{
+			"post": {
+				OperationID: "toggle" + title + "Mute",
+				Summary:     "Flips the mute status of a " + t,
+				Parameters:  []*openapi.Parameter{idParameter},
+				Responses: map[string]*openapi.Response{
+					"200": {Description: "The " + t + ", as changed.", Content: device},
+					"401": unauthorized,
+					"403": forbidden,
+					"404": notFound,
+					"503": failed,
+				},
+			},
+		}
+		document.Paths[apiV1Path+"/defaults/"+t] = map[string]*openapi.Operation{
+			"get": {
+				OperationID: "getDefault" + title,
+				Summary:     "The default " + t,
+				Responses: map[string]*openapi.Response{
+					"200": {Description: "The default " + t + ".", Content: device},
+					"401": unauthorized,
+					"403": forbidden,
+					"404": notFound,
+				},
+			},
+			"put": {
+				OperationID: "setDefault" + title,
+				Summary:     "Makes a " + t + " the default one and moves every stream to it",
+				RequestBody: &openapi.RequestBody{
+					Required: true,
+					Content:  openapi.JSON(openapi.Ref("CardDeviceReference")),
+				},
+				Responses: map[string]*openapi.Response{
+					"200": {Description: "The new default " + t + ".", Content: device},
+					"400": badRequest,
+					"401": unauthorized,
+					"403": forbidden,
+					"503": failed,
+				},
+			},
+		}
+	}
+
+	document.Paths[apiV1Path+"/cards"] = map[string]*openapi.Operation{
+		"get": {
+			OperationID: "listCards",
+			Summary:     "The cards",
+			Responses: map[string]*openapi.Response{
+				"200": {
+					Description: "The cards.",
+					Content:     openapi.JSON(&openapi.Schema{Type: "array", Items: openapi.Ref("Card")}),
+				},
+				"401": unauthorized,
+				"403": forbidden,
+			},
+		},
+	}
+	document.Paths[apiV1Path+"/cards/{id}"] = map[string]*openapi.Operation{
+		"get": {
+			OperationID: "getCard",
+			Summary:     "A card",
+			Parameters:  []*openapi.Parameter{idParameter},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "The card.", Content: openapi.JSON(openapi.Ref("Card"))},
+				"401": unauthorized,
+				"403": forbidden,
+				"404": notFound,
+			},
+		},
+	}
+	document.Paths[apiV1Path+"/cards/{id}/profile"] = map[string]*openapi.Operation{
+		"put": {
+			OperationID: "setCardProfile",
+			Summary:     "Sets the profile of a card",
+			Parameters:  []*openapi.Parameter{idParameter},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("ProfileRequest"))},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "The card, as changed.", Content: openapi.JSON(openapi.Ref("Card"))},
+				"400": badRequest,
+				"401": unauthorized,
+				"403": forbidden,
+				"404": notFound,
+				"503": failed,
+			},
+		},
+	}
+	document.Paths[apiV1Path+"/streams"] = map[string]*openapi.Operation{
+		"get": {
+			OperationID: "listStreams",
+			Summary:     "The streams which play to a sink or record from a source",
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "The streams.", Content: openapi.JSON(openapi.Ref("StreamsResponse"))},
+				"401": unauthorized,
+				"403": forbidden,
+				"503": unavailable,
+			},
+		},
+	}
+	document.Paths[apiV1Path+"/scenes"] = map[string]*openapi.Operation{
+		"get": {
+			OperationID: "listScenes",
+			Summary:     "The names of the scenes of the configuration",
+			Responses: map[string]*openapi.Response{
+				"200": {
+					Description: "The names of the scenes.",
+					Content:     openapi.JSON(&openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}}),
+				},
+				"401": unauthorized,
+				"403": forbidden,
+			},
+		},
+	}
+	document.Paths[apiV1Path+"/scenes/{name}/apply"] = map[string]*openapi.Operation{
+		"post": {
+			OperationID: "applyScene",
+			Summary:     "Brings the devices to a scene of the configuration",
+			Description: "The settings of the cards and devices which are not present are skipped.",
+			Parameters: []*openapi.Parameter{
+				{
+					Name:        "name",
+					In:          "path",
+					Required:    true,
+					Description: "The name of the scene, escaped as a path segment.",
+					Schema:      &openapi.Schema{Type: "string"},
+				},
+			},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "The device state, as changed.", Content: openapi.JSON(deviceState)},
+				"401": unauthorized,
+				"403": forbidden,
+				"404": notFound,
+				"503": failed,
+			},
+		},
+	}
+
+	return document
+}
+
+func newAPISchemas() map[string]*openapi.Schema {
+	// The requests identify their card device, or card, by name when it is set, and by index otherwise.
+	cardDeviceIdentifier := map[string]*openapi.Schema{
+		"type":  openapi.Ref("CardDeviceType"),
+		"index": {Type: "integer", Minimum: openapi.Float(0), Description: "Used when name is not set."},
+		"name": {
+			Type:        "string",
+			MinLength:   1,
+			Description: "A name, a description pattern or @default.",
+		},
+	}
+
+	withProperties := func(properties, more map[string]*openapi.Schema) map[string]*openapi.Schema {
+		merged := make(map[string]*openapi.Schema, len(properties)+len(more))
+		for name, schema := range properties {
+			merged[name] = schema
+		}
+		for name, schema := range more {
+			merged[name] = schema
+		}
+
+		return merged
+	}
+
+	// Every command of the websocket carries the fields of the matching request next to its op and id.
+	websocketCommand := func(op string, schemaName string) *openapi.Schema {
+		return &openapi.Schema{
+			AllOf: []*openapi.Schema{
+				{
+					Type:     "object",
+					Required: []string{"op"},
+					Properties: map[string]*openapi.Schema{
+						"op": {Type: "string", Enum: []interface{}{op}},
+						"id": {Type: "string", Description: "Echoed in the CommandResponse."},
+					},
+				},
+				openapi.Ref(schemaName),
+			},
+		}
+	}
+
+	deviceEvent := func(properties map[string]*openapi.Schema) *openapi.Schema {
+		return &openapi.Schema{
+			Type: "object",
+			Properties: withProperties(map[string]*openapi.Schema{
+				"type":   openapi.Ref("CardDeviceType"),
+				"device": openapi.Ref("CardDevice"),
+			}, properties),
+		}
+	}
+
+	return map[string]*openapi.Schema{
+		"Error": {
+			Type:     "object",
+			Required: []string{"message"},
+			Properties: map[string]*openapi.Schema{
+				"message": {Type: "string"},
+				"errors":  {Type: "array", Items: openapi.Ref("FieldError")},
+			},
+		},
+		"FieldError": {
+			Type:     "object",
+			Required: []string{"field", "message"},
+			Properties: map[string]*openapi.Schema{
+				"field": {
+					Type:        "string",
+					Description: "The path of the field, e.g. volume, or empty for the body as a whole.",
+				},
+				"message": {Type: "string"},
+			},
+		},
+		"LogLevel": {
+			Type:     "object",
+			Required: []string{"level"},
+			Properties: map[string]*openapi.Schema{
+				"level": {
+					Type: "string",
+					Enum: []interface{}{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"},
+				},
+			},
+		},
+		"Topic": {
+			Type: "string",
+			Enum: []interface{}{
+				"device_state",
+				"device_added",
+				"device_removed",
+				"volume_changed",
+				"mute_changed",
+				"default_changed",
+				"profile_changed",
+				"stream_moved",
+			},
+		},
+		"CardDeviceType": {Type: "string", Enum: []interface{}{"source", "sink"}},
+		"CardProfile": reportedEnum("headset_head_unit", "a2dp_sink_sbc", "a2dp_sink_aac",
+			"a2dp_sink_aptx", "a2dp_sink_aptx_hd", "a2dp_sink_ldac", "off"),
+		"CardProfileInput": {
+			Enum: []interface{}{
+				"headset_head_unit", "a2dp_sink_sbc", "a2dp_sink_aac", "a2dp_sink_aptx", "a2dp_sink_aptx_hd",
+				"a2dp_sink_ldac", "off", 1, 2, 3, 4, 5, 6, 7,
+			},
+			Description: "The name of a profile, or its number, as in the order of the names, which older clients send.",
+		},
+		"Bus":               reportedEnum("pci", "bluetooth", "usb", "firewire", "isa", "hdmi", "displayport"),
+		"DeviceState":       reportedEnum("running", "idle", "suspended"),
+		"BluetoothProtocol": reportedEnum("headset_head_unit", "a2dp_sink"),
+		"A2DPCodec":         reportedEnum("sbc", "aac", "aptx", "aptx_hd", "ldac", "lc3", "msbc", "faststream"),
+		"FormFactor": reportedEnum("internal", "headphone", "webcam", "headset", "speaker", "microphone",
+			"handset", "hands-free", "tv", "car", "hifi", "portable", "computer"),
+		"BatteryLevel": {
+			Type:        "integer",
+			Minimum:     openapi.Float(0),
+			Maximum:     openapi.Float(100),
+			Description: "The battery percentage of a Bluetooth device, which is missing when it is not reported.",
+		},
+		"Card": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"index":         {Type: "integer"},
+				"name":          {Type: "string"},
+				"driver":        {Type: "string"},
+				"description":   {Type: "string"},
+				"profiles":      {Type: "array", Items: openapi.Ref("CardProfile"), Nullable: true},
+				"activeProfile": openapi.Ref("CardProfile"),
+				"sourceIds":     {Type: "array", Items: &openapi.Schema{Type: "integer"}, Nullable: true},
+				"sinkIds":       {Type: "array", Items: &openapi.Schema{Type: "integer"}, Nullable: true},
+				"formFactor":    openapi.Ref("FormFactor"),
+				"bus":           openapi.Ref("Bus"),
+				"batteryLevel":  openapi.Ref("BatteryLevel"),
+			},
+		},
+		"CardDevice": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"index":             {Type: "integer"},
+				"name":              {Type: "string"},
+				"driver":            {Type: "string"},
+				"state":             openapi.Ref("DeviceState"),
+				"isDefault":         {Type: "boolean"},
+				"volume":            {Type: "number", Description: "A percentage, which may exceed 100."},
+				"isMuted":           {Type: "boolean"},
+				"cardIndex":         {Type: "integer"},
+				"description":       {Type: "string"},
+				"bluetoothProtocol": openapi.Ref("BluetoothProtocol"),
+				"a2dpCodec":         openapi.Ref("A2DPCodec"),
+				"formFactor":        openapi.Ref("FormFactor"),
+				"bus":               openapi.Ref("Bus"),
+				"batteryLevel":      openapi.Ref("BatteryLevel"),
+			},
+		},
+		"CardsWithDevices": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"cards":   {Type: "array", Items: openapi.Ref("Card"), Nullable: true},
+				"sources": {Type: "array", Items: openapi.Ref("CardDevice"), Nullable: true},
+				"sinks":   {Type: "array", Items: openapi.Ref("CardDevice"), Nullable: true},
+			},
+		},
+		"CardsWithDevicesResponse": {
+			AllOf: []*openapi.Schema{
+				openapi.Ref("CardsWithDevices"),
+				{
+					Type: "object",
+					Properties: map[string]*openapi.Schema{
+						"timestamp": {Type: "integer", Description: "When the state was read, in Unix milliseconds."},
+					},
+				},
+			},
+		},
+		"VolumeRequest": {
+			Type:     "object",
+			Required: []string{"type", "volume"},
+			Properties: withProperties(cardDeviceIdentifier, map[string]*openapi.Schema{
+				"volume": {
+					Type:        "number",
+					Minimum:     openapi.Float(0),
+					Description: "A percentage, which is capped at the configured maximum volume.",
+				},
+			}),
+		},
+		"VolumeStepRequest": {
+			Type:     "object",
+			Required: []string{"type", "step"},
+			Properties: withProperties(cardDeviceIdentifier, map[string]*openapi.Schema{
+				"step": {Type: "number", Description: "Percentage points, which may be negative."},
+			}),
+		},
+		"MuteRequest": {
+			Type:       "object",
+			Required:   []string{"type", "mute"},
+			Properties: withProperties(cardDeviceIdentifier, map[string]*openapi.Schema{"mute": {Type: "boolean"}}),
+		},
+		"MuteToggleRequest": {
+			Type:       "object",
+			Required:   []string{"type"},
+			Properties: cardDeviceIdentifier,
+		},
+		"DefaultCardDeviceRequest": {
+			Type:       "object",
+			Required:   []string{"type"},
+			Properties: cardDeviceIdentifier,
+		},
+		"CardProfileRequest": {
+			Type:     "object",
+			Required: []string{"profile"},
+			Properties: map[string]*openapi.Schema{
+				"index":   cardDeviceIdentifier["index"],
+				"name":    {Type: "string", MinLength: 1, Description: "A name or a description pattern."},
+				"profile": openapi.Ref("CardProfileInput"),
+			},
+		},
+		"CardDevicePatchRequest": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"volume": {
+					Type:        "number",
+					Minimum:     openapi.Float(0),
+					Description: "A percentage, which is capped at the configured maximum volume.",
+				},
+				"muted": {Type: "boolean"},
+			},
+			Description: "At least one of volume and muted must be set.",
+		},
+		"CardDeviceReference": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"index": cardDeviceIdentifier["index"],
+				"name":  cardDeviceIdentifier["name"],
+			},
+		},
+		"StepRequest": {
+			Type:     "object",
+			Required: []string{"step"},
+			Properties: map[string]*openapi.Schema{
+				"step": {Type: "number", Description: "Percentage points, which may be negative."},
+			},
+		},
+		"ProfileRequest": {
+			Type:       "object",
+			Required:   []string{"profile"},
+			Properties: map[string]*openapi.Schema{"profile": openapi.Ref("CardProfileInput")},
+		},
+		"AudioClient": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"index":           {Type: "integer"},
+				"cardDeviceIndex": {Type: "integer", Description: "The index of the sink or source of the stream."},
+				"name":            {Type: "string", Description: "The name of the client of the stream."},
+			},
+		},
+		"StreamsResponse": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"sinkInputs":    {Type: "array", Items: openapi.Ref("AudioClient")},
+				"sourceOutputs": {Type: "array", Items: openapi.Ref("AudioClient")},
+			},
+		},
+		"WebsocketClientMessage": {
+			OneOf: []*openapi.Schema{
+				openapi.Ref("WebsocketAuth"),
+				{
+					Type:        "object",
+					Required:    []string{"op"},
+					Description: "Asks for a new StateSync snapshot, e.g. after a lost patch.",
+					Properties:  map[string]*openapi.Schema{"op": {Type: "string", Enum: []interface{}{"resync"}}},
+				},
+				websocketCommand("setVolume", "VolumeRequest"),
+				websocketCommand("stepVolume", "VolumeStepRequest"),
+				websocketCommand("setMute", "MuteRequest"),
+				websocketCommand("toggleMute", "MuteToggleRequest"),
+				websocketCommand("setDefault", "DefaultCardDeviceRequest"),
+				websocketCommand("setProfile", "CardProfileRequest"),
+			},
+		},
+		"WebsocketAuth": {
+			Type:        "object",
+			Required:    []string{"op", "token"},
+			Description: "Authenticates the websocket, as its first message.",
+			Properties: map[string]*openapi.Schema{
+				"op":    {Type: "string", Enum: []interface{}{"auth"}},
+				"id":    {Type: "string"},
+				"token": {Type: "string"},
+			},
+		},
+		"WebsocketServerMessage": {
+			OneOf: []*openapi.Schema{
+				openapi.Ref("StateSync"),
+				openapi.Ref("Event"),
+				openapi.Ref("CommandResponse"),
+			},
+		},
+		"StateSync": {
+			Type:     "object",
+			Required: []string{"type", "seq"},
+			Description: "A snapshot of the device state, or the RFC 6902 JSON Patch which turns the previous one " +
+				"into the current one. seq grows by one with each message.",
+			Properties: map[string]*openapi.Schema{
+				"type":     {Type: "string", Enum: []interface{}{"snapshot", "patch"}},
+				"seq":      {Type: "integer"},
+				"snapshot": openapi.Ref("CardsWithDevices"),
+				"patch":    {Type: "array", Items: openapi.Ref("PatchOperation")},
+			},
+		},
+		"PatchOperation": {
+			Type:     "object",
+			Required: []string{"op", "path"},
+			Properties: map[string]*openapi.Schema{
+				"op":    {Type: "string", Enum: []interface{}{"add", "remove", "replace"}},
+				"path":  {Type: "string"},
+				"value": {},
+			},
+		},
+		"Event": {
+			Type:     "object",
+			Required: []string{"topic", "payload"},
+			Properties: map[string]*openapi.Schema{
+				"topic": openapi.Ref("Topic"),
+				"payload": {
+					OneOf: []*openapi.Schema{
+						openapi.Ref("CardsWithDevicesResponse"),
+						openapi.Ref("DeviceEvent"),
+						openapi.Ref("VolumeChangedEvent"),
+						openapi.Ref("DefaultChangedEvent"),
+						openapi.Ref("ProfileChangedEvent"),
+						openapi.Ref("StreamMovedEvent"),
+					},
+				},
+			},
+		},
+		"DeviceEvent": deviceEvent(nil),
+		"VolumeChangedEvent": deviceEvent(map[string]*openapi.Schema{
+			"previousVolume": {Type: "number"},
+		}),
+		"DefaultChangedEvent": deviceEvent(map[string]*openapi.Schema{
+			"previousDevice": {AllOf: []*openapi.Schema{openapi.Ref("CardDevice")}, Nullable: true},
+		}),
+		"ProfileChangedEvent": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"card":            openapi.Ref("Card"),
+				"previousProfile": openapi.Ref("CardProfile"),
+			},
+		},
+		"StreamMovedEvent": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"type":                openapi.Ref("CardDeviceType"),
+				"clientIndex":         {Type: "integer"},
+				"fromCardDeviceIndex": {Type: "integer"},
+				"toCardDeviceIndex":   {Type: "integer"},
+				"toCardDeviceName":    {Type: "string"},
+			},
+		},
+		"CommandResponse": {
+			Type:     "object",
+			Required: []string{"type", "id"},
+			Properties: map[string]*openapi.Schema{
+				"type":   {Type: "string", Enum: []interface{}{"ack", "error"}},
+				"id":     {Type: "string"},
+				"error":  {Type: "string"},
+				"fields": {Type: "array", Items: openapi.Ref("FieldError")},
+			},
+		},
+	}
+}
+
+// reportedEnum describes an enum read from the sound server, which is reported by the name of its value.
+func reportedEnum(names ...string) *openapi.Schema {
+	return &openapi.Schema{
+		Type: "string",
+		Description: fmt.Sprintf("One of %v, or unknown when it has not been reported. A value which go-cctl does "+
+			"not know is reported as it was read.", strings.Join(names, ", ")),
+	}
+}
@@ -2,22 +2,24 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"net"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/adaptor/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/gofiber/websocket/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sadesyllas/go-cctl/app"
-	"github.com/sadesyllas/go-cctl/app/device/audio"
-	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
 	"github.com/sadesyllas/go-cctl/app/pubsub"
 	"github.com/sadesyllas/go-cctl/app/web"
+	"github.com/sadesyllas/go-cctl/app/web/openapi"
 )
 
 const metricsPath = "/metrics"
@@ -34,117 +36,197 @@ var reqDurCnt = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Name: "cctl_metric_duration",
 	Help: "Duration of all HTTP requests by status code, method and path.",
 	Buckets: []float64{
-		0.000000001, // 1ns
-		0.000000002,
-		0.000000005,
-		0.00000001, // 10ns
-		0.00000002,
-		0.00000005,
-		0.0000001, // 100ns
-		0.0000002,
-		0.0000005,
-		0.000001, // 1µs
-		0.000002,
-		0.000005,
-		0.00001, // 10µs
-		0.00002,
-		0.00005,
-		0.0001, // 100µs
-		0.0002,
-		0.0005,
 		0.001, // 1ms
-		0.002,
+		0.0025,
 		0.005,
 		0.01, // 10ms
-		0.02,
+		0.025,
 		0.05,
-		0.1, // 100 ms
-		0.2,
+		0.1, // 100ms
+		0.25,
 		0.5,
 		1.0, // 1s
-		2.0,
+		2.5,
 		5.0,
 		10.0, // 10s
-		15.0,
-		20.0,
-		30.0,
 	},
 },
 	[]string{"status_code", "method", "path"},
 )
 
-func Start(port uint16) {
-	webApp := fiber.New()
+var reqInFlightGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "cctl_http_requests_in_flight",
+	Help: "Number of HTTP requests being handled.",
+})
+
+var eventBus *pubsub.Bus
+
+// Start serves the same routes on every address, i.e. a TCP host:port or the path of a unix socket.
+func Start(addresses []string, bus *pubsub.Bus) {
+	eventBus = bus
+
+	go recordDeviceState(bus)
+	go trackDeviceState(bus)
+
+	webApp := fiber.New(fiber.Config{ErrorHandler: handleError})
+
+	middleware := []fiber.Handler{handleMetrics, handleTraceContext, handleTLS, handleCORS, handleAuth, handleUnmatched}
+	for _, handler := range middleware {
+		middlewareHandlers[reflect.ValueOf(handler).Pointer()] = true
+	}
 
 	webApp.Use(handleMetrics)
+	webApp.Use(handleTraceContext)
+	webApp.Use(handleTLS)
+	webApp.Use(handleCORS)
 
-	webApp.Get("/", handleCORS(func(c *fiber.Ctx) error { return c.Redirect("/audio") }))
+	// The web UI holds nothing secret and is registered before the authentication, so that it can be loaded with
+	// e.g. ?token=... in its address, which it then authenticates to the API with. The same goes for the API
+	// document, which tools generating clients fetch.
+	webApp.Get("/", func(c *fiber.Ctx) error { return c.Redirect(uiPath + "/") })
+	webApp.Get(uiPath+"/*", handleUIRequest)
+	webApp.Get(openAPIPath, handleOpenAPIRequest)
+
+	webApp.Use(handleAuth)
 
 	webApp.Get(metricsPath, adaptor.HTTPHandler(promhttp.Handler()))
 
-	webApp.Options("/audio", handleCORS(func(*fiber.Ctx) error { return nil }))
-	webApp.Get("/audio", handleCORS(handleAudioRequest))
+	webApp.Get("/debug/loglevel", adaptor.HTTPHandler(app.LogLevel))
+	webApp.Put("/debug/loglevel", adaptor.HTTPHandler(app.LogLevel))
+
+	registerAPIRoutes(webApp.Group(apiV1Path))
+
+	webApp.Get("/audio", handleAudioRequest)
+	webApp.Get("/audio/ws", websocket.New(handleWebsocketRequest))
+	webApp.Get("/audio/events", handleEventsRequest)
+
+	// The routes below are kept for the clients which predate the versioned API.
+	webApp.Post("/audio/volume", handleDeprecated, handleVolumeRequest)
+	webApp.Post("/audio/volume/step", handleDeprecated, handleVolumeStepRequest)
+	webApp.Post("/audio/mute", handleDeprecated, handleMuteRequest)
+	webApp.Post("/audio/mute/toggle", handleDeprecated, handleMuteToggleRequest)
+	webApp.Post("/audio/default", handleDeprecated, handleDefaultCardDeviceRequest)
+	webApp.Post("/audio/profile", handleDeprecated, handleCardProfileRequest)
+
+	webApp.Use(handleUnmatched)
 
-	webApp.Options("/audio/ws", handleCORS(func(*fiber.Ctx) error { return nil }))
-	webApp.Get("/audio/ws", handleCORS(websocket.New(handleWebsocketRequest)))
+	listeners := []net.Listener{}
+	for _, address := range addresses {
+		listener, err := listen(address)
+		if err != nil {
+			app.Logger.Fatalw("Could not listen", "address", address, "error", err)
+		}
+
+		app.Logger.Infow("Listening", "address", address)
+
+		listeners = append(listeners, listener)
+	}
 
-	webApp.Options("/audio/volume", handleCORS(func(*fiber.Ctx) error { return nil }))
-	webApp.Post("/audio/volume", handleCORS(handleVolumeRequest))
+	if err := webApp.Listener(mergeListeners(listeners...)); err != nil {
+		app.Logger.Fatalw("The web server has stopped", "error", err)
+	}
+}
 
-	webApp.Options("/audio/mute", handleCORS(func(*fiber.Ctx) error { return nil }))
-	webApp.Post("/audio/mute", handleCORS(handleMuteRequest))
+// middlewareHandlers holds the code pointers of the middleware, whose routes fiber does not tell apart from the
+// others, even though they all have the path "/".
+var middlewareHandlers = map[uintptr]bool{}
+
+// isMiddlewareRoute reports whether the route only runs middleware, which fiber merges into a single route when
+// they are registered one after the other.
+func isMiddlewareRoute(route *fiber.Route) bool {
+	for _, handler := range route.Handlers {
+		if !middlewareHandlers[reflect.ValueOf(handler).Pointer()] {
+			return false
+		}
+	}
+
+	return len(route.Handlers) != 0
+}
 
-	webApp.Options("/audio/default", handleCORS(func(*fiber.Ctx) error { return nil }))
-	webApp.Post("/audio/default", handleCORS(handleDefaultCardDeviceRequest))
+// unmatchedLocal is the key among the locals which marks the requests that no route has handled.
+const unmatchedLocal = "unmatched"
 
-	webApp.Options("/audio/profile", handleCORS(func(*fiber.Ctx) error { return nil }))
-	webApp.Post("/audio/profile", handleCORS(handleCardProfileRequest))
+// handleUnmatched is registered after every route, so that it only handles the requests which matched none of them,
+// which would otherwise be labelled by the route of the last middleware they went through.
+func handleUnmatched(c *fiber.Ctx) error {
+	c.Locals(unmatchedLocal, true)
 
-	webApp.Listen(fmt.Sprintf(":%v", port))
+	return fiber.ErrNotFound
 }
 
+// handleMetrics labels the requests by the template of the route which handled them, e.g. "/audio/volume", so
+// that path parameters do not create a new series per value.
 func handleMetrics(c *fiber.Ctx) error {
 	start := time.Now()
-	method := c.Method()
-	path := c.Path()
+	method := utils.CopyString(c.Method())
 
-	if strings.HasPrefix(path, metricsPath) {
+	if strings.HasPrefix(c.Path(), metricsPath) {
 		return c.Next()
 	}
 
-	if err := c.Next(); err != nil {
-		reqCnt.WithLabelValues(strconv.Itoa(0), method, path).Inc()
+	reqInFlightGauge.Inc()
+	defer reqInFlightGauge.Dec()
 
-		elapsed := float64(time.Since(start).Nanoseconds()) / 1000000000
-		reqDurCnt.WithLabelValues(strconv.Itoa(0), method, path).Observe(elapsed)
+	// The error is handled here, instead of being returned to fiber, so that the status code it results in can
+	// be recorded.
+	if err := c.Next(); err != nil {
+		if err := c.App().Config().ErrorHandler(c, err); err != nil {
+			c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
 
-		return err
+	// Requests which a middleware has answered, e.g. by rejecting their credentials, are left with the route of
+	// that middleware, whose path would mix them up with those of the routes under it.
+	path := c.Route().Path
+	if c.Locals(unmatchedLocal) != nil {
+		path = "(unmatched)"
+	} else if isMiddlewareRoute(c.Route()) && method == fiber.MethodOptions {
+		path = "(preflight)"
+	} else if isMiddlewareRoute(c.Route()) {
+		path = "(rejected)"
 	}
 
 	statusCode := strconv.Itoa(c.Response().StatusCode())
 
 	reqCnt.WithLabelValues(statusCode, method, path).Inc()
 
-	elapsed := float64(time.Since(start).Nanoseconds()) / 1000000000
+	elapsed := time.Since(start).Seconds()
 	reqDurCnt.WithLabelValues(statusCode, method, path).Observe(elapsed)
 
 	return nil
 }
 
-func handleCORS(handler func(*fiber.Ctx) error) func(*fiber.Ctx) error {
-	return func(c *fiber.Ctx) error {
-		c.Context().Response.Header.Add("Access-Control-Allow-Origin", "*")
-		c.Context().Response.Header.Add("Access-Control-Allow-Headers", "Content-Type")
+// handleError responds with the message of the error, keeping the status code which the handler has already set,
+// e.g. 400 for a bad request, unless the error carries one of its own.
+//
+// Validation errors are sent as JSON, listing the fields which are invalid.
+func handleError(c *fiber.Ctx, err error) error {
+	statusCode := fiber.StatusInternalServerError
+	if fiberErr, ok := err.(*fiber.Error); ok {
+		statusCode = fiberErr.Code
+	} else if c.Response().StatusCode() >= fiber.StatusBadRequest {
+		statusCode = c.Response().StatusCode()
+	}
 
-		return handler(c)
+	var validationError *openapi.ValidationError
+	if errors.As(err, &validationError) {
+		errorJson, _ := json.Marshal(fiber.Map{"message": err.Error(), "errors": validationError.Errors})
+
+		c.Context().SetContentType("application/json")
+
+		return c.Status(statusCode).Send(errorJson)
 	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+
+	return c.Status(statusCode).SendString(err.Error())
 }
 
 func handleAudioRequest(c *fiber.Ctx) error {
-	_, span := app.Span("/audio")
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio")
 	defer span.End()
 
-	cardsWithDevices := emitDeviceState()
+	cardsWithDevices := emitDeviceState(ctx)
 	deviceStateJsonBytes, _ := json.Marshal(web.NewCardsWithDevicesResponse(cardsWithDevices))
 	deviceStateJson := string(deviceStateJsonBytes)
 
@@ -153,79 +235,88 @@ func handleAudioRequest(c *fiber.Ctx) error {
 	return c.SendString(deviceStateJson)
 }
 
-func handleWebsocketRequest(c *websocket.Conn) {
-	remoteAddr := c.RemoteAddr()
-	defer func() { app.Logger.Infof("Websocket connection from %v has been closed\n", remoteAddr) }()
-
-	app.Logger.Infof("New websocket connection from %v\n", remoteAddr)
-
-	_, span := app.Span("/audio/ws")
+func handleVolumeRequest(c *fiber.Ctx) error {
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio/volume")
 	defer span.End()
-	inbound := make(chan pubsub.Message)
 
-	pubsub.Register(pubsub.TopicDeviceState, inbound)
+	var volumeRequest web.VolumeRequest
+	if err := decodeRequest(c.Body(), "volume", "VolumeRequest", &volumeRequest); err != nil {
+		c.SendStatus(400)
 
-	for {
-		msg := <-inbound
+		return err
+	}
 
-		if msg, ok := msg.Payload.(*audio.CardsWithDevices); ok {
-			app.Logger.Debug("Sending message down the websocket")
+	if err := setVolume(volumeRequest, ctx); err != nil {
+		c.SendStatus(400)
 
-			if err := c.WriteJSON(msg); err != nil {
-				break
-			}
-		}
+		return err
 	}
+
+	c.SendStatus(200)
+
+	return nil
 }
 
-func handleVolumeRequest(c *fiber.Ctx) error {
-	ctx, span := app.Span("/audio/volume")
+func handleMuteRequest(c *fiber.Ctx) error {
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio/mute")
 	defer span.End()
 
-	var volumeRequest web.VolumeRequest
-	if err := json.Unmarshal(c.Body(), &volumeRequest); err != nil {
+	var muteRequest web.MuteRequest
+	if err := decodeRequest(c.Body(), "mute", "MuteRequest", &muteRequest); err != nil {
 		c.SendStatus(400)
 
-		return fmt.Errorf("bad volume request")
+		return err
 	}
 
-	cardDeviceType, err := carddevice.ParseableCardDeviceType(volumeRequest.Type).Parse()
-	if err != nil {
+	if err := setMute(muteRequest, ctx); err != nil {
 		c.SendStatus(400)
 
-		return fmt.Errorf("bad volume request: invalid card device type")
+		return err
 	}
 
-	audio.SetVolume(cardDeviceType, volumeRequest.Index, volumeRequest.Volume, ctx)
-
-	emitDeviceState()
-
 	c.SendStatus(200)
 
 	return nil
 }
 
-func handleMuteRequest(c *fiber.Ctx) error {
-	ctx, span := app.Span("/audio/mute")
+func handleVolumeStepRequest(c *fiber.Ctx) error {
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio/volume/step")
 	defer span.End()
 
-	var muteRequest web.MuteRequest
-	if err := json.Unmarshal(c.Body(), &muteRequest); err != nil {
+	var volumeStepRequest web.VolumeStepRequest
+	if err := decodeRequest(c.Body(), "volume step", "VolumeStepRequest", &volumeStepRequest); err != nil {
 		c.SendStatus(400)
 
-		return fmt.Errorf("bad volume request")
+		return err
 	}
 
-	cardDeviceType, err := carddevice.ParseableCardDeviceType(muteRequest.Type).Parse()
-	if err != nil {
+	if err := stepVolume(volumeStepRequest, ctx); err != nil {
 		c.SendStatus(400)
 
-		return fmt.Errorf("bad volume request: invalid card device type")
+		return err
 	}
 
-	audio.ToggleMute(cardDeviceType, muteRequest.Index, muteRequest.Mute, ctx)
+	c.SendStatus(200)
 
-	emitDeviceState()
+	return nil
+}
+
+func handleMuteToggleRequest(c *fiber.Ctx) error {
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio/mute/toggle")
+	defer span.End()
+
+	var muteToggleRequest web.MuteToggleRequest
+	if err := decodeRequest(c.Body(), "mute toggle", "MuteToggleRequest", &muteToggleRequest); err != nil {
+		c.SendStatus(400)
+
+		return err
+	}
+
+	if err := toggleMute(muteToggleRequest, ctx); err != nil {
+		c.SendStatus(400)
+
+		return err
+	}
 
 	c.SendStatus(200)
 
@@ -233,58 +324,45 @@ func handleMuteRequest(c *fiber.Ctx) error {
 }
 
 func handleDefaultCardDeviceRequest(c *fiber.Ctx) error {
-	ctx, span := app.Span("/audio/default")
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio/default")
 	defer span.End()
 
 	var defaultCardDeviceRequest web.DefaultCardDeviceRequest
-	if err := json.Unmarshal(c.Body(), &defaultCardDeviceRequest); err != nil {
+	if err := decodeRequest(c.Body(), "default card device", "DefaultCardDeviceRequest", &defaultCardDeviceRequest); err != nil {
 		c.SendStatus(400)
 
-		return fmt.Errorf("bad volume request")
+		return err
 	}
 
-	cardDeviceType, err := carddevice.ParseableCardDeviceType(defaultCardDeviceRequest.Type).Parse()
-	if err != nil {
+	if err := setDefaultCardDevice(defaultCardDeviceRequest, ctx); err != nil {
 		c.SendStatus(400)
 
-		return fmt.Errorf("bad volume request: invalid card device type")
+		return err
 	}
 
-	audio.SetDefaultCardDevice(cardDeviceType, defaultCardDeviceRequest.Index, ctx)
-
-	audio.MoveAudioClients(cardDeviceType, defaultCardDeviceRequest.Index, defaultCardDeviceRequest.Name, ctx)
-
-	emitDeviceState()
-
 	c.SendStatus(200)
 
 	return nil
 }
 
 func handleCardProfileRequest(c *fiber.Ctx) error {
-	ctx, span := app.Span("/audio/profile")
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio/profile")
 	defer span.End()
 
 	var cardProfileRequest web.CardProfileRequest
-	if err := json.Unmarshal(c.Body(), &cardProfileRequest); err != nil {
+	if err := decodeRequest(c.Body(), "card profile", "CardProfileRequest", &cardProfileRequest); err != nil {
 		c.SendStatus(400)
 
-		return fmt.Errorf("bad volume request")
+		return err
 	}
 
-	audio.SetCardProfile(cardProfileRequest.Index, cardProfileRequest.Profile, ctx)
+	if err := setCardProfile(cardProfileRequest, ctx); err != nil {
+		c.SendStatus(400)
 
-	emitDeviceState()
+		return err
+	}
 
 	c.SendStatus(200)
 
 	return nil
 }
-
-func emitDeviceState() *audio.CardsWithDevices {
-	cardsWithDevices := audio.FetchCardsWithDevices()
-
-	pubsub.Send(pubsub.NewMessage(pubsub.TopicDeviceState, cardsWithDevices))
-
-	return cardsWithDevices
-}
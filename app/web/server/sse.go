@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+	"github.com/sadesyllas/go-cctl/app/web"
+)
+
+const (
+	sseReplayBufferSize = 32
+	sseHeartbeatPeriod  = 15 * time.Second
+	sseDeviceStateEvent = "device_state"
+)
+
+type sseEvent struct {
+	id   uint64
+	data []byte
+}
+
+// replayBuffer keeps the latest device state events, so that a reconnecting client can resume from the last
+// event it has seen.
+type replayBuffer struct {
+	lock    sync.Mutex
+	events  []sseEvent
+	nextID  uint64
+	changed chan struct{}
+}
+
+var deviceStateReplayBuffer = newReplayBuffer()
+
+func newReplayBuffer() *replayBuffer {
+	return &replayBuffer{
+		events:  make([]sseEvent, 0, sseReplayBufferSize),
+		nextID:  1,
+		changed: make(chan struct{}),
+	}
+}
+
+func (buffer *replayBuffer) append(data []byte) {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	if len(buffer.events) == sseReplayBufferSize {
+		buffer.events = append(buffer.events[:0], buffer.events[1:]...)
+	}
+
+	buffer.events = append(buffer.events, sseEvent{id: buffer.nextID, data: data})
+	buffer.nextID++
+
+	// Wakes up every stream waiting for new events.
+	close(buffer.changed)
+	buffer.changed = make(chan struct{})
+}
+
+// since returns the events after the one with the given id, along with a channel which is closed when a new
+// event is appended.
+//
+// When the given id is 0, has already left the buffer, or is unknown, because e.g. the server has restarted, only
+// the latest event is returned.
+func (buffer *replayBuffer) since(id uint64) ([]sseEvent, <-chan struct{}) {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	if len(buffer.events) == 0 {
+		return nil, buffer.changed
+	}
+
+	oldestID := buffer.events[0].id
+	if id == 0 || id+1 < oldestID || id >= buffer.nextID {
+		return []sseEvent{buffer.events[len(buffer.events)-1]}, buffer.changed
+	}
+
+	if id+1 == buffer.nextID {
+		return nil, buffer.changed
+	}
+
+	events := make([]sseEvent, len(buffer.events[id+1-oldestID:]))
+	copy(events, buffer.events[id+1-oldestID:])
+
+	return events, buffer.changed
+}
+
+func recordDeviceState(bus *pubsub.Bus) {
+	subscription := event.DeviceStateTopic.Subscribe(bus, "sse", pubsub.DropOldest, 8)
+	defer subscription.Close()
+
+	// The device state is published on every poll, whether it has changed or not, while the streams only need the
+	// changes, and keep alive through the heartbeats in between. The sections which could not be fetched are taken
+	// from the previous device state, rather than sent as if their cards or devices had been removed.
+	previous := (*audio.CardsWithDevices)(nil)
+	previousState := []byte(nil)
+
+	for msg := range subscription.C() {
+		if payload, ok := event.DeviceStateTopic.Payload(msg); ok {
+			payload = audio.KeepFetched(previous, payload)
+			previous = payload
+
+			state, err := json.Marshal(payload)
+			if err == nil && bytes.Equal(state, previousState) {
+				continue
+			}
+
+			previousState = state
+
+			data, err := json.Marshal(web.NewCardsWithDevicesResponse(payload))
+			if err != nil {
+				app.LoggerWithContext(msg.Context()).Errorw("Could not encode the device state for the event stream", "error", err)
+
+				continue
+			}
+
+			deviceStateReplayBuffer.append(data)
+		}
+	}
+}
+
+func handleEventsRequest(c *fiber.Ctx) error {
+	ctx, span := app.SpanWithContext(c.UserContext(), "/audio/events")
+	defer span.End()
+
+	logger := app.LoggerWithContext(ctx).With("remote_addr", c.Context().RemoteAddr().String())
+
+	lastEventID, _ := strconv.ParseUint(c.Get("Last-Event-ID"), 10, 64)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		logger.Info("New event stream")
+		defer func() { logger.Info("Event stream has been closed") }()
+
+		heartbeatTicker := time.NewTicker(sseHeartbeatPeriod)
+		defer heartbeatTicker.Stop()
+
+		// Tells the client how long to wait before reconnecting.
+		fmt.Fprintf(w, "retry: %v\n\n", (5 * time.Second).Milliseconds())
+
+		for {
+			events, changed := deviceStateReplayBuffer.since(lastEventID)
+
+			for _, event := range events {
+				fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", event.id, sseDeviceStateEvent, event.data)
+
+				lastEventID = event.id
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			select {
+			case <-changed:
+			case <-heartbeatTicker.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+		}
+	})
+
+	return nil
+}
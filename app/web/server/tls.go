@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/config"
+)
+
+// tlsRecordTypeHandshake is the first byte sent by a TLS client.
+const tlsRecordTypeHandshake = 0x16
+
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+// newTLSConfig loads the certificate of the configuration, generating a self-signed one first if needed, and logs
+// its fingerprint, so that clients can pin it.
+func newTLSConfig(tlsConfig config.TLSConfig) (*tls.Config, error) {
+	certFile, keyFile := tlsConfig.CertFile, tlsConfig.KeyFile
+	if certFile == "" {
+		certFile, keyFile = filepath.Join(config.Dir(), "tls.crt"), filepath.Join(config.Dir(), "tls.key")
+	}
+
+	if tlsConfig.SelfSigned {
+		if _, err := os.Stat(certFile); os.IsNotExist(err) {
+			if err := generateSelfSignedCertificate(certFile, keyFile); err != nil {
+				return nil, fmt.Errorf("could not generate a self-signed certificate: %v", err)
+			}
+
+			app.Logger.Infow("Generated a self-signed certificate", "cert_file", certFile, "key_file", keyFile)
+		}
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the certificate: %v", err)
+	}
+
+	app.Logger.Infow("Serving HTTPS",
+		"cert_file", certFile, "sha256_fingerprint", fingerprint(certificate.Certificate[0]))
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func generateSelfSignedCertificate(certFile string, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: hostname, Organization: []string{"go-cctl"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+	}
+
+	if hostname != "" {
+		template.DNSNames = append(template.DNSNames, hostname, hostname+".local")
+	}
+
+	// The certificate covers every address of the host, since phones usually reach it by its LAN address.
+	if addresses, err := net.InterfaceAddrs(); err == nil {
+		for _, address := range addresses {
+			if ipNet, ok := address.(*net.IPNet); ok {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0755); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+
+	certificatePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate})
+
+	return ioutil.WriteFile(certFile, certificatePEM, 0644)
+}
+
+// fingerprint returns the SHA-256 fingerprint of a DER certificate, e.g. "AB:CD:...".
+func fingerprint(certificate []byte) string {
+	sum := sha256.Sum256(certificate)
+
+	hexBytes := make([]string, len(sum))
+	for i, b := range sum {
+		hexBytes[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(hexBytes, ":")
+}
+
+// handleTLS rejects plain HTTP requests from outside localhost once TLS is enabled, since they would expose the
+// credentials.
+func handleTLS(c *fiber.Ctx) error {
+	conn, ok := c.Context().Conn().(*sniffConn)
+	if !ok || conn.isTLS() || isLocal(c) {
+		return c.Next()
+	}
+
+	return fiber.NewError(fiber.StatusForbidden, "plain HTTP is only served to localhost, use HTTPS")
+}
+
+// sniffListener serves both TLS and plain connections on the same port, telling them apart by their first byte.
+type sniffListener struct {
+	net.Listener
+	tlsConfig *tls.Config
+}
+
+func (listener *sniffListener) Accept() (net.Conn, error) {
+	conn, err := listener.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sniffConn{Conn: conn, tlsConfig: listener.tlsConfig}, nil
+}
+
+// sniffConn waits for the first read or write to find out whether the client speaks TLS, so that a slow client does
+// not hold up Accept.
+type sniffConn struct {
+	net.Conn
+	tlsConfig *tls.Config
+	once      sync.Once
+	active    net.Conn
+	tls       bool
+	err       error
+}
+
+func (conn *sniffConn) sniff() {
+	reader := bufio.NewReader(conn.Conn)
+
+	first, err := reader.Peek(1)
+	if err != nil {
+		conn.err = err
+
+		return
+	}
+
+	peeked := &peekedConn{Conn: conn.Conn, reader: reader}
+
+	if first[0] == tlsRecordTypeHandshake {
+		conn.active, conn.tls = tls.Server(peeked, conn.tlsConfig), true
+	} else {
+		conn.active = peeked
+	}
+}
+
+func (conn *sniffConn) Read(b []byte) (int, error) {
+	conn.once.Do(conn.sniff)
+	if conn.err != nil {
+		return 0, conn.err
+	}
+
+	return conn.active.Read(b)
+}
+
+func (conn *sniffConn) Write(b []byte) (int, error) {
+	conn.once.Do(conn.sniff)
+	if conn.err != nil {
+		return 0, conn.err
+	}
+
+	return conn.active.Write(b)
+}
+
+func (conn *sniffConn) isTLS() bool {
+	conn.once.Do(conn.sniff)
+
+	return conn.tls
+}
+
+// peekedConn reads through the reader which has peeked at the connection.
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (conn *peekedConn) Read(b []byte) (int, error) {
+	return conn.reader.Read(b)
+}
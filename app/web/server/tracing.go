@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+)
+
+// traceContextLocal is the key of the request context among the locals, through which it reaches the websocket
+// handler.
+const traceContextLocal = "traceContext"
+
+// handleTraceContext continues the trace of the caller, as described by the W3C traceparent header, if any.
+func handleTraceContext(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestHeaderCarrier{header: &c.Request().Header})
+
+	c.SetUserContext(ctx)
+	c.Locals(traceContextLocal, ctx)
+
+	return c.Next()
+}
+
+// websocketContext returns the request context of the websocket upgrade.
+func websocketContext(locals func(string) interface{}) context.Context {
+	if ctx, ok := locals(traceContextLocal).(context.Context); ok {
+		return ctx
+	}
+
+	return context.Background()
+}
+
+// requestHeaderCarrier adapts the fasthttp request headers to propagation.TextMapCarrier.
+type requestHeaderCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (carrier requestHeaderCarrier) Get(key string) string {
+	return string(carrier.header.Peek(key))
+}
+
+func (carrier requestHeaderCarrier) Set(key string, value string) {
+	carrier.header.Set(key, value)
+}
+
+func (carrier requestHeaderCarrier) Keys() []string {
+	keys := []string{}
+
+	carrier.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+
+	return keys
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sadesyllas/go-cctl/app/web/ui"
+)
+
+const uiPath = "/ui"
+
+// uiAssetsPath holds the assets of the web UI, whose names change with their content, so they can be cached forever.
+//
+// It is not SvelteKit's default, _app, since go:embed leaves out directories starting with an underscore.
+const uiAssetsPath = "app/"
+
+var uiFS = ui.FS()
+
+// handleUIRequest serves the embedded web UI, falling back to its index.html for paths which are not files, so
+// that the UI can route them itself.
+func handleUIRequest(c *fiber.Ctx) error {
+	// The UI refers to its files relatively to its root.
+	if !strings.HasPrefix(c.Path(), uiPath+"/") {
+		return c.Redirect(uiPath + "/")
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+c.Params("*")), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	content, err := fs.ReadFile(uiFS, name)
+	if err != nil {
+		if path.Ext(name) != "" {
+			return fiber.ErrNotFound
+		}
+
+		name = "index.html"
+
+		content, err = fs.ReadFile(uiFS, name)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "the web UI has not been built into this binary")
+		}
+	}
+
+	if strings.HasPrefix(name, uiAssetsPath) {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	} else {
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+	}
+
+	c.Type(strings.TrimPrefix(path.Ext(name), "."))
+
+	return c.Send(content)
+}
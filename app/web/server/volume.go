@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+)
+
+const (
+	volumeCoalescingWindow = 50 * time.Millisecond
+	volumeEmissionInterval = 250 * time.Millisecond
+)
+
+type volumeTarget struct {
+	t     carddevice.CardDeviceType
+	index uint64
+}
+
+type pendingVolume struct {
+	// relative is set while only steps have been requested, whose sum volumePercentage then is, which is added to
+	// the volume of the card device as it is when they are applied.
+	relative         bool
+	volumePercentage float64
+	ctx              context.Context
+	applied          chan struct{}
+	err              error
+}
+
+// volumeCoalescer applies only the latest volume requested for a card device within a short window, so that
+// dragging a volume slider does not spawn a pacmd process for every intermediate value.
+type volumeCoalescer struct {
+	lock    sync.Mutex
+	pending map[volumeTarget]*pendingVolume
+	// writing serializes the writes to each card device, so that a window never starts writing before the write
+	// of the previous one has returned, which could otherwise be carried out last and leave a stale volume.
+	writing map[volumeTarget]*sync.Mutex
+	// read returns the volume, write sets it and applied runs after every write, e.g. to emit the device state.
+	read    func(t carddevice.CardDeviceType, index uint64, ctx context.Context) (float64, error)
+	write   func(t carddevice.CardDeviceType, index uint64, volumePercentage float64, ctx context.Context) error
+	applied func(ctx context.Context)
+}
+
+var volumes = newVolumeCoalescer(readVolume, audio.SetVolume, deviceStateEmission.trigger)
+
+func newVolumeCoalescer(
+	read func(t carddevice.CardDeviceType, index uint64, ctx context.Context) (float64, error),
+	write func(t carddevice.CardDeviceType, index uint64, volumePercentage float64, ctx context.Context) error,
+	applied func(ctx context.Context)) *volumeCoalescer {
+	return &volumeCoalescer{
+		pending: make(map[volumeTarget]*pendingVolume),
+		writing: make(map[volumeTarget]*sync.Mutex),
+		read:    read,
+		write:   write,
+		applied: applied,
+	}
+}
+
+func readVolume(t carddevice.CardDeviceType, index uint64, ctx context.Context) (float64, error) {
+	cardDevice, err := findCardDevice(t, index, "", true, ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return cardDevice.Volume, nil
+}
+
+// set returns once the requested volume, or a later one for the same card device, has been applied, along with
+// the error of applying it.
+func (coalescer *volumeCoalescer) set(
+	t carddevice.CardDeviceType,
+	index uint64,
+	volumePercentage float64,
+	ctx context.Context) error {
+	return coalescer.request(volumeTarget{t: t, index: index}, false, volumePercentage, ctx)
+}
+
+// step changes the volume by step, on top of the volumes and steps requested before it, like set.
+func (coalescer *volumeCoalescer) step(
+	t carddevice.CardDeviceType,
+	index uint64,
+	step float64,
+	ctx context.Context) error {
+	return coalescer.request(volumeTarget{t: t, index: index}, true, step, ctx)
+}
+
+func (coalescer *volumeCoalescer) request(
+	target volumeTarget,
+	relative bool,
+	amount float64,
+	ctx context.Context) error {
+	coalescer.lock.Lock()
+	pending, ok := coalescer.pending[target]
+	if ok {
+		if relative {
+			pending.volumePercentage += amount
+		} else {
+			pending.relative = false
+			pending.volumePercentage = amount
+		}
+		pending.ctx = ctx
+	} else {
+		pending = &pendingVolume{
+			relative:         relative,
+			volumePercentage: amount,
+			ctx:              ctx,
+			applied:          make(chan struct{}),
+		}
+		coalescer.pending[target] = pending
+
+		time.AfterFunc(volumeCoalescingWindow, func() { coalescer.apply(target) })
+	}
+	coalescer.lock.Unlock()
+
+	<-pending.applied
+
+	return pending.err
+}
+
+// serialize runs fn while no volume is written to the card device, for the other changes which read its state
+// before writing it, e.g. toggling its mute status.
+func (coalescer *volumeCoalescer) serialize(t carddevice.CardDeviceType, index uint64, fn func() error) error {
+	writing := coalescer.lockWriting(volumeTarget{t: t, index: index})
+	defer writing.Unlock()
+
+	return fn()
+}
+
+func (coalescer *volumeCoalescer) apply(target volumeTarget) {
+	// The pending volume is only taken once the previous write has returned, so that it keeps collecting the
+	// latest requests in the meantime.
+	writing := coalescer.lockWriting(target)
+	defer writing.Unlock()
+
+	coalescer.lock.Lock()
+	pending := coalescer.pending[target]
+	delete(coalescer.pending, target)
+	coalescer.lock.Unlock()
+
+	volumePercentage := pending.volumePercentage
+	if pending.relative {
+		currentVolumePercentage, err := coalescer.read(target.t, target.index, pending.ctx)
+		if err != nil {
+			pending.err = err
+
+			close(pending.applied)
+
+			return
+		}
+
+		volumePercentage = math.Max(math.Min(currentVolumePercentage+volumePercentage, config.Get().Audio.MaxVolume), 0)
+	}
+
+	pending.err = coalescer.write(target.t, target.index, volumePercentage, pending.ctx)
+
+	close(pending.applied)
+
+	coalescer.applied(pending.ctx)
+}
+
+// lockWriting locks the writes to the card device, whose mutex is kept for as long as go-cctl runs, since card
+// devices come and go far too rarely for it to matter.
+func (coalescer *volumeCoalescer) lockWriting(target volumeTarget) *sync.Mutex {
+	coalescer.lock.Lock()
+	writing, ok := coalescer.writing[target]
+	if !ok {
+		writing = new(sync.Mutex)
+		coalescer.writing[target] = writing
+	}
+	coalescer.lock.Unlock()
+
+	writing.Lock()
+
+	return writing
+}
+
+// throttle runs fn at most once per interval, while guaranteeing that it runs after the latest trigger.
+//
+// fn runs with the context of the latest trigger.
+type throttle struct {
+	lock      sync.Mutex
+	interval  time.Duration
+	last      time.Time
+	scheduled bool
+	ctx       context.Context
+	fn        func(context.Context)
+}
+
+var deviceStateEmission = &throttle{
+	interval: volumeEmissionInterval,
+	fn:       func(ctx context.Context) { emitDeviceState(ctx) },
+}
+
+func (throttle *throttle) trigger(ctx context.Context) {
+	throttle.lock.Lock()
+	defer throttle.lock.Unlock()
+
+	throttle.ctx = ctx
+
+	if throttle.scheduled {
+		return
+	}
+
+	throttle.scheduled = true
+
+	time.AfterFunc(throttle.interval-time.Since(throttle.last), func() {
+		throttle.lock.Lock()
+		throttle.scheduled = false
+		throttle.last = time.Now()
+		ctx := throttle.ctx
+		throttle.lock.Unlock()
+
+		throttle.fn(ctx)
+	})
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+)
+
+// fakeVolumeWriter records the writes of a volumeCoalescer, which block until released when block is set, and
+// reads back the volume last written.
+type fakeVolumeWriter struct {
+	lock    sync.Mutex
+	volume  float64
+	writes  []string
+	active  int
+	overlap bool
+	block   chan struct{}
+	err     error
+}
+
+func (writer *fakeVolumeWriter) write(
+	t carddevice.CardDeviceType,
+	index uint64,
+	volumePercentage float64,
+	_ context.Context) error {
+	writer.lock.Lock()
+	writer.active++
+	writer.overlap = writer.overlap || writer.active > 1
+	writer.writes = append(writer.writes, fmt.Sprintf("%v %v %v", t, index, volumePercentage))
+	block := writer.block
+	writer.lock.Unlock()
+
+	if block != nil {
+		<-block
+	}
+
+	writer.lock.Lock()
+	writer.active--
+	writer.volume = volumePercentage
+	writer.lock.Unlock()
+
+	return writer.err
+}
+
+func (writer *fakeVolumeWriter) read(carddevice.CardDeviceType, uint64, context.Context) (float64, error) {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	return writer.volume, nil
+}
+
+func (writer *fakeVolumeWriter) recorded() ([]string, bool) {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	return append([]string(nil), writer.writes...), writer.overlap
+}
+
+func TestVolumeCoalescer(t *testing.T) {
+	type request struct {
+		t      carddevice.CardDeviceType
+		index  uint64
+		volume float64
+		step   bool
+	}
+
+	tests := []struct {
+		name     string
+		requests []request
+		want     []string
+	}{
+		{"single", []request{{carddevice.Sink, 1, 40, false}}, []string{"sink 1 40"}},
+		{
+			"burst",
+			[]request{
+				{carddevice.Sink, 1, 10, false},
+				{carddevice.Sink, 1, 20, false},
+				{carddevice.Sink, 1, 30, false},
+			},
+			[]string{"sink 1 30"},
+		},
+		{
+			"steps",
+			[]request{
+				{carddevice.Sink, 1, 5, true},
+				{carddevice.Sink, 1, 5, true},
+				{carddevice.Sink, 1, -2, true},
+			},
+			[]string{"sink 1 58"},
+		},
+		{
+			"step after a volume",
+			[]request{{carddevice.Sink, 1, 20, false}, {carddevice.Sink, 1, 5, true}},
+			[]string{"sink 1 25"},
+		},
+		{
+			"volume after a step",
+			[]request{{carddevice.Sink, 1, 5, true}, {carddevice.Sink, 1, 20, false}},
+			[]string{"sink 1 20"},
+		},
+		{
+			"step beyond the max volume",
+			[]request{{carddevice.Sink, 1, 90, true}},
+			[]string{"sink 1 100"},
+		},
+		{
+			"separate card devices",
+			[]request{
+				{carddevice.Sink, 1, 10, false},
+				{carddevice.Source, 1, 20, false},
+				{carddevice.Sink, 2, 30, false},
+			},
+			[]string{"sink 1 10", "sink 2 30", "source 1 20"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writer := &fakeVolumeWriter{volume: 50}
+			coalescer := newVolumeCoalescer(writer.read, writer.write, func(context.Context) {})
+
+			var wait sync.WaitGroup
+			for _, r := range test.requests {
+				wait.Add(1)
+				go func(r request) {
+					defer wait.Done()
+
+					set := coalescer.set
+					if r.step {
+						set = coalescer.step
+					}
+
+					if err := set(r.t, r.index, r.volume, context.Background()); err != nil {
+						t.Errorf("set() error = %v", err)
+					}
+				}(r)
+
+				// Keeps the order of the requests of a burst.
+				time.Sleep(time.Millisecond)
+			}
+			wait.Wait()
+
+			writes, _ := writer.recorded()
+			sort.Strings(writes)
+
+			if !reflect.DeepEqual(writes, test.want) {
+				t.Errorf("writes = %v, want %v", writes, test.want)
+			}
+		})
+	}
+}
+
+func TestVolumeCoalescerWritesInOrder(t *testing.T) {
+	writer := &fakeVolumeWriter{block: make(chan struct{})}
+	coalescer := newVolumeCoalescer(writer.read, writer.write, func(context.Context) {})
+
+	results := make(chan error, 3)
+	set := func(volume float64) {
+		go func() { results <- coalescer.set(carddevice.Sink, 1, volume, context.Background()) }()
+	}
+
+	// The first window starts writing and blocks, while the next window collects two more requests.
+	set(10)
+	time.Sleep(2 * volumeCoalescingWindow)
+	set(20)
+	time.Sleep(2 * volumeCoalescingWindow)
+	set(30)
+	time.Sleep(2 * volumeCoalescingWindow)
+
+	if writes, _ := writer.recorded(); !reflect.DeepEqual(writes, []string{"sink 1 10"}) {
+		t.Fatalf("writes while the first one is blocked = %v, want only the first one", writes)
+	}
+
+	close(writer.block)
+
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("set() error = %v", err)
+		}
+	}
+
+	writes, overlap := writer.recorded()
+	if want := []string{"sink 1 10", "sink 1 30"}; !reflect.DeepEqual(writes, want) {
+		t.Errorf("writes = %v, want %v", writes, want)
+	}
+
+	if overlap {
+		t.Errorf("writes to the same card device overlapped")
+	}
+}
+
+func TestVolumeCoalescerStepsInOrder(t *testing.T) {
+	writer := &fakeVolumeWriter{volume: 50, block: make(chan struct{})}
+	coalescer := newVolumeCoalescer(writer.read, writer.write, func(context.Context) {})
+
+	results := make(chan error, 2)
+	step := func() {
+		go func() { results <- coalescer.step(carddevice.Sink, 1, 5, context.Background()) }()
+	}
+
+	// The second step is only added to the volume once the first one has been written.
+	step()
+	time.Sleep(2 * volumeCoalescingWindow)
+	step()
+	time.Sleep(2 * volumeCoalescingWindow)
+	close(writer.block)
+
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("step() error = %v", err)
+		}
+	}
+
+	if writes, _ := writer.recorded(); !reflect.DeepEqual(writes, []string{"sink 1 55", "sink 1 60"}) {
+		t.Errorf("writes = %v, want both steps to add up", writes)
+	}
+}
+
+func TestVolumeCoalescerReportsErrors(t *testing.T) {
+	writer := &fakeVolumeWriter{err: fmt.Errorf("no sink found")}
+	coalescer := newVolumeCoalescer(writer.read, writer.write, func(context.Context) {})
+
+	if err := coalescer.set(carddevice.Sink, 1, 10, context.Background()); err != writer.err {
+		t.Errorf("set() error = %v, want %v", err, writer.err)
+	}
+}
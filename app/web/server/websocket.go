@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sadesyllas/go-cctl/app"
+	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/audio/event"
+	"github.com/sadesyllas/go-cctl/app/pubsub"
+	"github.com/sadesyllas/go-cctl/app/web"
+	"github.com/sadesyllas/go-cctl/app/web/jsonpatch"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = (wsPongWait * 9) / 10
+)
+
+var wsConnGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "cctl_websocket_connections",
+	Help: "Number of live websocket connections.",
+})
+
+func handleWebsocketRequest(c *websocket.Conn) {
+	ctx, span := app.SpanWithContext(websocketContext(c.Locals), "/audio/ws")
+	defer span.End()
+
+	logger := app.LoggerWithContext(ctx).With("remote_addr", c.RemoteAddr().String())
+	defer func() { logger.Info("Websocket connection has been closed") }()
+
+	logger.Info("New websocket connection")
+
+	wsConnGauge.Inc()
+	defer wsConnGauge.Dec()
+
+	// Without an explicit choice of topics, the websocket follows the device state, as described by
+	// web.StateSyncResponse. Otherwise, every message is wrapped in a web.EventResponse naming its topic.
+	topics, enveloped := []pubsub.Topic{pubsub.TopicDeviceState}, false
+	if value := c.Query("topics"); value != "" {
+		parsedTopics, err := parseTopics(value)
+		if err != nil {
+			logger.Errorw("Rejected websocket connection", "error", err)
+
+			c.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseUnsupportedData, err.Error()),
+				time.Now().Add(wsWriteWait))
+
+			return
+		}
+
+		topics, enveloped = parsedTopics, true
+	}
+
+	inbound, stopReading := readWebsocket(c)
+	defer stopReading()
+
+	clientRole := websocketRole(c.Locals)
+	if clientRole == roleNone {
+		authenticatedRole, err := authenticateWebsocket(c, inbound)
+		if err != nil {
+			logger.Errorw("Rejected websocket connection", "error", err)
+
+			c.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()),
+				time.Now().Add(wsWriteWait))
+
+			return
+		}
+
+		clientRole = authenticatedRole
+	}
+
+	subscription := eventBus.Register("websocket", pubsub.DropOldest, 8, topics...)
+	defer subscription.Close()
+
+	pingTicker := time.NewTicker(wsPingPeriod)
+	defer pingTicker.Stop()
+
+	stateSync := new(stateSync)
+
+	if !enveloped {
+		if err := writeWebsocket(c, stateSync.snapshot(audio.FetchCardsWithDevices(ctx))); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case data, ok := <-inbound:
+			if !ok {
+				return
+			}
+
+			var request web.WebsocketRequest
+			if err := json.Unmarshal(data, &request); err != nil {
+				logger.Errorw("Bad websocket request", "error", err)
+
+				continue
+			}
+
+			if request.Op == "resync" {
+				if enveloped {
+					continue
+				}
+
+				if err := writeWebsocket(c, stateSync.snapshot(audio.FetchCardsWithDevices(ctx))); err != nil {
+					return
+				}
+
+				continue
+			}
+
+			var err error
+			if clientRole < roleControl {
+				err = fmt.Errorf("the control role is required")
+			} else {
+				err = handleWebsocketCommand(request.Op, data, ctx)
+			}
+
+			if err != nil {
+				logger.Errorw("Websocket command failed", "op", request.Op, "id", request.ID, "error", err)
+			}
+
+			if err := writeWebsocket(c, web.NewCommandResponse(request.ID, err)); err != nil {
+				return
+			}
+		case <-pingTicker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case msg, ok := <-subscription.C():
+			if !ok {
+				return
+			}
+
+			var response interface{}
+			if enveloped {
+				response = web.NewEventResponse(msg.Topic.String(), msg.Payload())
+			} else if payload, ok := event.DeviceStateTopic.Payload(msg); ok {
+				if stateSyncResponse := stateSync.patch(payload); stateSyncResponse != nil {
+					response = stateSyncResponse
+				}
+			}
+
+			if response == nil {
+				continue
+			}
+
+			logger.Debugw("Sending message down the websocket", "topic", msg.Topic)
+
+			_, pushSpan := app.SpanWithContext(msg.Context(), "Websocket Push")
+			err := writeWebsocket(c, response)
+			pushSpan.End()
+
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func handleWebsocketCommand(op string, data []byte, ctx context.Context) error {
+	ctx, span := app.SpanWithContext(ctx, "/audio/ws "+op)
+	defer span.End()
+
+	var request interface{}
+	var schemaName string
+	var command func() error
+
+	switch op {
+	case "setVolume":
+		volumeRequest := new(web.VolumeRequest)
+		request, schemaName, command = volumeRequest, "VolumeRequest", func() error {
+			return setVolume(*volumeRequest, ctx)
+		}
+	case "stepVolume":
+		volumeStepRequest := new(web.VolumeStepRequest)
+		request, schemaName, command = volumeStepRequest, "VolumeStepRequest", func() error {
+			return stepVolume(*volumeStepRequest, ctx)
+		}
+	case "toggleMute":
+		muteToggleRequest := new(web.MuteToggleRequest)
+		request, schemaName, command = muteToggleRequest, "MuteToggleRequest", func() error {
+			return toggleMute(*muteToggleRequest, ctx)
+		}
+	case "setMute":
+		muteRequest := new(web.MuteRequest)
+		request, schemaName, command = muteRequest, "MuteRequest", func() error {
+			return setMute(*muteRequest, ctx)
+		}
+	case "setDefault":
+		defaultCardDeviceRequest := new(web.DefaultCardDeviceRequest)
+		request, schemaName, command = defaultCardDeviceRequest, "DefaultCardDeviceRequest", func() error {
+			return setDefaultCardDevice(*defaultCardDeviceRequest, ctx)
+		}
+	case "setProfile":
+		cardProfileRequest := new(web.CardProfileRequest)
+		request, schemaName, command = cardProfileRequest, "CardProfileRequest", func() error {
+			return setCardProfile(*cardProfileRequest, ctx)
+		}
+	default:
+		return fmt.Errorf("unknown op: %v", op)
+	}
+
+	if err := decodeRequest(data, op, schemaName, request); err != nil {
+		return err
+	}
+
+	return command()
+}
+
+func parseTopics(value string) ([]pubsub.Topic, error) {
+	topics := []pubsub.Topic{}
+
+	for _, name := range strings.Split(value, ",") {
+		topic, err := pubsub.ParseableTopic(strings.TrimSpace(name)).Parse()
+		if err != nil {
+			return nil, err
+		}
+
+		topics = append(topics, topic)
+	}
+
+	return topics, nil
+}
+
+func writeWebsocket(c *websocket.Conn, value interface{}) error {
+	c.SetWriteDeadline(time.Now().Add(wsWriteWait))
+
+	return c.WriteJSON(value)
+}
+
+// readWebsocket keeps reading from the websocket, so that close frames and pongs are processed as soon as
+// they arrive, and returns a channel with the received messages, which is closed once the connection is gone.
+//
+// The handler must call stop before it returns, which closes the connection and waits for the reading to stop,
+// since fiber reuses the connection once the handler has returned.
+func readWebsocket(c *websocket.Conn) (inbound <-chan []byte, stop func()) {
+	messages := make(chan []byte)
+	done := make(chan struct{})
+
+	c.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go func() {
+		defer close(messages)
+
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			select {
+			case messages <- data:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return messages, func() {
+		close(done)
+		c.Close()
+
+		for range messages {
+		}
+	}
+}
+
+// stateSync tracks the device state last sent down a websocket.
+//
+// The sections which could not be fetched are taken from the device state last sent, so that the client does not see
+// their cards or devices removed, only to see them added back once they can be fetched again.
+type stateSync struct {
+	seq   uint64
+	state *audio.CardsWithDevices
+}
+
+func (stateSync *stateSync) snapshot(cardsWithDevices *audio.CardsWithDevices) *web.StateSyncResponse {
+	cardsWithDevices = audio.KeepFetched(stateSync.state, cardsWithDevices)
+
+	stateSync.seq++
+	stateSync.state = cardsWithDevices
+
+	return &web.StateSyncResponse{
+		Type:     "snapshot",
+		Seq:      stateSync.seq,
+		Snapshot: cardsWithDevices,
+	}
+}
+
+// patch returns nil when the device state has not changed since it was last sent.
+func (stateSync *stateSync) patch(cardsWithDevices *audio.CardsWithDevices) *web.StateSyncResponse {
+	cardsWithDevices = audio.KeepFetched(stateSync.state, cardsWithDevices)
+
+	patch, err := jsonpatch.Create(stateSync.state, cardsWithDevices)
+	if err != nil {
+		app.Logger.Errorw("Could not create the device state patch, sending a snapshot instead", "error", err)
+
+		return stateSync.snapshot(cardsWithDevices)
+	}
+
+	if len(patch) == 0 {
+		return nil
+	}
+
+	stateSync.seq++
+	stateSync.state = cardsWithDevices
+
+	return &web.StateSyncResponse{
+		Type:  "patch",
+		Seq:   stateSync.seq,
+		Patch: patch,
+	}
+}
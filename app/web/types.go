@@ -1,34 +1,88 @@
 package web
 
 import (
+	"errors"
 	"time"
 
 	"github.com/sadesyllas/go-cctl/app/device/audio"
+	"github.com/sadesyllas/go-cctl/app/device/pacmd/audioclient"
 	"github.com/sadesyllas/go-cctl/app/device/pacmd/card"
 	"github.com/sadesyllas/go-cctl/app/device/pacmd/card/carddevice"
+	"github.com/sadesyllas/go-cctl/app/web/jsonpatch"
+	"github.com/sadesyllas/go-cctl/app/web/openapi"
 )
 
+// The requests below identify their card device, or card, by Name when it is set, which may also be an alias or a
+// description pattern, as resolved by audio.CardsWithDevices.FindCardDevice and FindCard, and by Index otherwise.
+
 type VolumeRequest struct {
-	Type   string
-	Index  uint64
-	Volume float64
+	Type   string  `json:"type"`
+	Index  uint64  `json:"index"`
+	Name   string  `json:"name,omitempty"`
+	Volume float64 `json:"volume"`
 }
 
 type MuteRequest struct {
-	Type  string
-	Index uint64
-	Mute  bool
+	Type  string `json:"type"`
+	Index uint64 `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Mute  bool   `json:"mute"`
 }
 
 type DefaultCardDeviceRequest struct {
-	Type  string
-	Index uint64
-	Name  string
+	Type  string `json:"type"`
+	Index uint64 `json:"index"`
+	Name  string `json:"name,omitempty"`
+}
+
+// VolumeStepRequest changes the volume of a card device by Step percentage points, which may be negative.
+type VolumeStepRequest struct {
+	Type  string  `json:"type"`
+	Index uint64  `json:"index"`
+	Name  string  `json:"name,omitempty"`
+	Step  float64 `json:"step"`
+}
+
+// MuteToggleRequest flips the mute status of a card device.
+type MuteToggleRequest struct {
+	Type  string `json:"type"`
+	Index uint64 `json:"index"`
+	Name  string `json:"name,omitempty"`
 }
 
 type CardProfileRequest struct {
-	Index   uint64
-	Profile card.CardProfile
+	Index   uint64           `json:"index"`
+	Name    string           `json:"name,omitempty"`
+	Profile card.CardProfile `json:"profile"`
+}
+
+// CardDevicePatchRequest changes the volume, the mute status, or both, of the source or sink in its path.
+type CardDevicePatchRequest struct {
+	Volume *float64 `json:"volume,omitempty"`
+	Muted  *bool    `json:"muted,omitempty"`
+}
+
+// StepRequest changes the volume of the source or sink in its path by Step percentage points.
+type StepRequest struct {
+	Step float64 `json:"step"`
+}
+
+// CardDeviceReference identifies the source or sink to make the default one, by Name when it is set and by Index
+// otherwise, like the requests above.
+type CardDeviceReference struct {
+	Index uint64 `json:"index"`
+	Name  string `json:"name,omitempty"`
+}
+
+// ProfileRequest sets the profile of the card in its path.
+type ProfileRequest struct {
+	Profile card.CardProfile `json:"profile"`
+}
+
+// StreamsResponse lists the streams of the sound server, i.e. what plays to a sink and what records from a source.
+type StreamsResponse struct {
+	SinkInputs    []*audioclient.AudioClient `json:"sinkInputs"`
+	SourceOutputs []*audioclient.AudioClient `json:"sourceOutputs"`
 }
 
 type CardsWithDevicesResponse struct {
@@ -46,3 +100,69 @@ func NewCardsWithDevicesResponse(value *audio.CardsWithDevices) CardsWithDevices
 		Timestamp: uint64(time.Now().UnixMilli()),
 	}
 }
+
+// EventResponse wraps the messages sent down a websocket which has chosen its topics.
+type EventResponse struct {
+	Topic   string      `json:"topic"`
+	Payload interface{} `json:"payload"`
+}
+
+func NewEventResponse(topic string, payload interface{}) EventResponse {
+	if value, ok := payload.(*audio.CardsWithDevices); ok {
+		payload = NewCardsWithDevicesResponse(value)
+	}
+
+	return EventResponse{
+		Topic:   topic,
+		Payload: payload,
+	}
+}
+
+// StateSyncResponse is sent down a websocket which follows the device state.
+//
+// The first message, as well as the reply to a resync request, is a "snapshot" carrying the full device state.
+// Every following message is a "patch", carrying the RFC 6902 JSON Patch which turns the previous device state
+// into the current one. Seq grows by one with each message, so that a client can detect a lost message and
+// request a resync.
+type StateSyncResponse struct {
+	Type     string                  `json:"type"`
+	Seq      uint64                  `json:"seq"`
+	Snapshot *audio.CardsWithDevices `json:"snapshot,omitempty"`
+	Patch    jsonpatch.Patch         `json:"patch,omitempty"`
+}
+
+// WebsocketRequest is a message received from a websocket.
+//
+// Commands carry the fields of the matching REST request next to op and id, e.g.
+// {"op":"setVolume","id":"1","type":"sink","index":3,"volume":40}.
+type WebsocketRequest struct {
+	Op string `json:"op"`
+	ID string `json:"id"`
+	// Token authenticates the websocket, when op is "auth".
+	Token string `json:"token,omitempty"`
+}
+
+// CommandResponse acknowledges, or reports the failure of, a command received from a websocket.
+//
+// Fields lists the fields of the command which did not pass validation, if that is why it failed.
+type CommandResponse struct {
+	Type   string               `json:"type"`
+	ID     string               `json:"id"`
+	Error  string               `json:"error,omitempty"`
+	Fields []openapi.FieldError `json:"fields,omitempty"`
+}
+
+func NewCommandResponse(id string, err error) CommandResponse {
+	if err != nil {
+		commandResponse := CommandResponse{Type: "error", ID: id, Error: err.Error()}
+
+		var validationError *openapi.ValidationError
+		if errors.As(err, &validationError) {
+			commandResponse.Fields = validationError.Errors
+		}
+
+		return commandResponse
+	}
+
+	return CommandResponse{Type: "ack", ID: id}
+}
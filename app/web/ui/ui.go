@@ -0,0 +1,17 @@
+// Package ui embeds the web UI, as built by `pnpm build` in web/ into dist/build.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed dist
+var dist embed.FS
+
+// FS returns the files of the built web UI, which has no index.html when it has not been built.
+func FS() fs.FS {
+	build, _ := fs.Sub(dist, "dist/build")
+
+	return build
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sadesyllas/go-cctl/app"
 	"github.com/sadesyllas/go-cctl/app/appletUpdater"
+	"github.com/sadesyllas/go-cctl/app/cli"
+	"github.com/sadesyllas/go-cctl/app/config"
+	"github.com/sadesyllas/go-cctl/app/device/audio/differ"
+	"github.com/sadesyllas/go-cctl/app/device/audio/metrics"
 	"github.com/sadesyllas/go-cctl/app/device/audio/monitor"
+	"github.com/sadesyllas/go-cctl/app/device/audio/scene"
 	"github.com/sadesyllas/go-cctl/app/device/audio/watchdog"
 	"github.com/sadesyllas/go-cctl/app/pubsub"
 	"github.com/sadesyllas/go-cctl/app/web/server"
@@ -14,35 +21,95 @@ import (
 )
 
 func main() {
+	if cli.IsCommand(os.Args[1:]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
+	configPath := pflag.StringP("config", "c", config.DefaultPath(), "The configuration file")
 	port := pflag.Uint16P("port", "p", 0, "The web server port")
+	listen := pflag.String("listen", "", "The host:port or the unix socket path to listen on, instead of the port")
+	maxVolume := pflag.Float64("max-volume", 0, "The volume percentage no volume change can exceed")
+	tracingExporter := pflag.String("tracing-exporter", "",
+		"The tracing exporter, one of none, jaeger, otlp-grpc, otlp-http or stdout")
+	tracingSamplingRatio := pflag.Float64("tracing-sampling-ratio", 0, "The ratio of traces to sample")
+	logLevel := pflag.String("log-level", "info", "The log level, one of debug, info, warn or error")
+	logFormat := pflag.String("log-format", "json", "The log format, json or console")
 	pflag.Parse()
 
-	if *port == 0 {
+	stopLogging, err := app.SetupLogging(app.LoggingOptions{Level: *logLevel, Format: *logFormat})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		os.Exit(2)
+	}
+	defer stopLogging()
+
+	err = config.Load(*configPath, func(c *config.Config) error {
+		if err := config.ApplyEnv(c); err != nil {
+			return err
+		}
+
+		if pflag.CommandLine.Changed("port") {
+			c.Server.Port = *port
+		}
+
+		if pflag.CommandLine.Changed("listen") {
+			c.Server.Listen = *listen
+		}
+
+		if pflag.CommandLine.Changed("max-volume") {
+			c.Audio.MaxVolume = *maxVolume
+		}
+
+		if pflag.CommandLine.Changed("tracing-exporter") {
+			c.Tracing.Exporter = *tracingExporter
+		}
+
+		if pflag.CommandLine.Changed("tracing-sampling-ratio") {
+			c.Tracing.SamplingRatio = *tracingSamplingRatio
+		}
+
+		return nil
+	})
+	if err != nil {
+		app.Logger.Fatal(err)
+	}
+
+	if len(config.Get().Server.ListenAddresses()) == 0 {
 		pflag.Usage()
 
 		os.Exit(1)
 	}
 
-	app.SetupLogging()
+	go config.Watch()
 
-	stopTracing := app.SetupTracing()
+	tracingConfig := config.Get().Tracing
+	stopTracing := app.SetupTracing(app.TracingOptions{
+		Exporter:      tracingConfig.Exporter,
+		Endpoint:      tracingConfig.Endpoint,
+		Insecure:      tracingConfig.Insecure,
+		SamplingRatio: tracingConfig.SamplingRatio,
+		Environment:   tracingConfig.Environment,
+	})
 	defer stopTracing()
 
 	var wait sync.WaitGroup
 	wait.Add(1)
 
-	go pubsub.Start()
-	go monitor.Start()
-	go watchdog.Start()
-	go appletUpdater.Start()
-	go server.Start(*port)
+	bus := pubsub.NewBus()
+	prometheus.MustRegister(bus)
+
+	go differ.Start(bus)
+	go metrics.Start(bus)
+	go monitor.Start(bus)
+	go watchdog.Start(bus)
+	go scene.Start(bus)
+	go appletUpdater.Start(bus)
+	go server.Start(config.Get().Server.ListenAddresses(), bus)
 
-	// tc := make(chan pubsub.Message)
-	// pubsub.Register(pubsub.TopicDeviceState, tc)
+	// subscription := bus.Register("debug", pubsub.DropOldest, 1, pubsub.TopicDeviceState)
 
-	// var msg pubsub.Message
-	// for {
-	// 	msg = <-tc
+	// for msg := range subscription.C() {
 	// 	j, _ := json.Marshal(msg.Payload.(*audio.CardsWithDevices))
 	// 	app.Logger.Debugf("[PUBSUB MSG] %v\n", string(j))
 	// }
@@ -1,21 +1,10 @@
 #!/bin/bash
 
-function kill_cctl {
-  killall go-cctl
-}
-
-trap kill_cctl INT
-
-trap kill_cctl TERM
-
-trap kill_cctl HUP
-
 cd "$(dirname "$0")"
 
-kill_cctl &> /dev/null
-
-go run . -p 3003 &
+killall go-cctl &> /dev/null
 
-cd web
+# The UI is embedded in the binary, so it has to be built first.
+(cd web && pnpm build)
 
-pnpm preview -- --host 0.0.0.0 --port 3005
+exec go run . -p 3003
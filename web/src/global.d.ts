@@ -2,6 +2,7 @@
 interface ImportMeta {
   env: {
     VITE_API_PORT: string;
+    VITE_API_TOKEN?: string;
   };
 }
 
@@ -2,12 +2,18 @@ import type { Writable } from 'svelte/store';
 
 import { browser } from '$app/env';
 
+// Without a port, the API is on the origin of the UI, which go-cctl serves itself.
 const API_PORT = <string>import.meta.env.VITE_API_PORT;
 
-let hostname = './';
+export let apiBaseUrl = './';
+
+// The token is only needed when the API is not reached through localhost. It can also be given in the address of
+// the page, e.g. ?token=...
+export let apiToken = <string>import.meta.env.VITE_API_TOKEN || '';
 
 if (browser) {
-  hostname = `${window.location.protocol}//${window.location.hostname}:${API_PORT}`;
+  apiBaseUrl = API_PORT ? `${window.location.protocol}//${window.location.hostname}:${API_PORT}` : window.location.origin;
+  apiToken = new URLSearchParams(window.location.search).get('token') || apiToken;
 }
 
 export type ApiError = { status: number; statusText: string; url: string; data?: { [key: string]: unknown } };
@@ -23,12 +29,16 @@ type ApiOptions = RequestInit & {
 };
 
 export async function send<T>(path: string, _options?: ApiOptions): Promise<{ headers: Headers; body: T }> {
-  const { baseUrl = hostname, fetch: f, params, tracker, ...options } = { ..._options };
+  const { baseUrl = apiBaseUrl, fetch: f, params, tracker, ...options } = { ..._options };
 
   if (tracker) {
     tracker.set(true);
   }
 
+  if (apiToken) {
+    options.headers = { ...options.headers, Authorization: `Bearer ${apiToken}` };
+  }
+
   if (options.body && !(options.body instanceof File) && !(options.body instanceof FormData)) {
     options.headers = { ...options.headers, 'Content-Type': 'application/json' };
     options.body = typeof options.body === 'string' ? options.body : JSON.stringify(options.body);
@@ -1,16 +1,9 @@
-import type { AudioDevices, BluetoothAudioDeviceProfile } from './types';
+import type { AudioDevices, BluetoothAudioDeviceProfile, StateSyncMessage } from './types';
 
 import { writable } from 'svelte/store';
-import { browser } from '$app/env';
-import { get, post } from '$lib/api';
+import { apiBaseUrl, apiToken, get, patch, put } from '$lib/api';
 
-const API_PORT = <string>import.meta.env.VITE_API_PORT;
-
-let hostname = './';
-
-if (browser) {
-  hostname = `${window.location.protocol}//${window.location.hostname}:${API_PORT}`;
-}
+const hostname = apiBaseUrl;
 
 export const devices = writable<AudioDevices>(undefined);
 
@@ -31,8 +24,8 @@ export async function getDevices(): Promise<void> {
 }
 
 export function connectAudioWS(): () => void {
-  const url = `ws://${window.location.hostname}:${API_PORT}/audio/ws`;
-  const ws = new WebSocket(url, []);
+  const url = `${hostname.replace(/^http/, 'ws')}/audio/ws`;
+  const ws = new WebSocket(apiToken ? `${url}?token=${encodeURIComponent(apiToken)}` : url, []);
 
   ws.onopen = async () => {
     console.log(`Created a websocket to ${url}`);
@@ -48,31 +41,75 @@ export function connectAudioWS(): () => void {
     setTimeout(connectAudioWS, 5_000);
   };
 
+  let seq = 0;
+  let state: Omit<AudioDevices, 'timestamp'>;
+
   ws.onmessage = ({ data }: MessageEvent) => {
-    const _devices = JSON.parse(data);
+    const message = <StateSyncMessage>JSON.parse(data);
 
-    // if (_devices.timestamp > latestStateTimestamp) {
-    //   latestStateTimestamp = _devices.timestamp;
+    if (message.type !== 'snapshot' && message.type !== 'patch') {
+      return;
+    }
 
-    devices.set(_devices);
-    // }
+    if (message.type === 'snapshot') {
+      state = message.snapshot;
+    } else if (!state || message.seq !== seq + 1) {
+      console.error(`Missed device state updates after seq ${seq}, requesting a resync`);
+
+      ws.send(JSON.stringify({ op: 'resync' }));
+
+      return;
+    } else {
+      state = applyPatch(state, message.patch);
+    }
+
+    seq = message.seq;
+    latestStateTimestamp = Date.now();
+
+    devices.set({ ...state, timestamp: latestStateTimestamp });
   };
 
   return () => ws.close();
 }
 
 export async function setVolume(type: 'source' | 'sink', index: number, volume: number): Promise<void> {
-  await post(`${hostname}/audio/volume`, JSON.stringify({ type, index, volume }));
+  await patch(`${hostname}/api/v1/${type}s/${index}`, JSON.stringify({ volume }));
 }
 
 export async function toggleMute(type: 'source' | 'sink', index: number, mute: boolean): Promise<void> {
-  await post(`${hostname}/audio/mute`, JSON.stringify({ type, index, mute }));
+  await patch(`${hostname}/api/v1/${type}s/${index}`, JSON.stringify({ muted: mute }));
 }
 
 export async function setProfile(index: number, profile: BluetoothAudioDeviceProfile): Promise<void> {
-  await post(`${hostname}/audio/profile`, JSON.stringify({ index, profile }));
+  await put(`${hostname}/api/v1/cards/${index}/profile`, JSON.stringify({ profile }));
 }
 
 export async function setDefault(type: 'source' | 'sink', index: number, name: string): Promise<void> {
-  await post(`${hostname}/audio/default`, JSON.stringify({ type, index, name }));
+  await put(`${hostname}/api/v1/defaults/${type}`, JSON.stringify({ index, name }));
+}
+
+function applyPatch<T>(document: T, patch: StateSyncMessage['patch']): T {
+  const root = { document: JSON.parse(JSON.stringify(document)) };
+
+  for (const { op, path, value } of patch) {
+    const keys = ['document', ...path.split('/').slice(1).map((key) => key.replace(/~1/g, '/').replace(/~0/g, '~'))];
+    const key = keys.pop();
+    const parent = keys.reduce((target, key) => target[key], <Record<string, unknown>>root);
+
+    if (Array.isArray(parent)) {
+      if (op === 'add') {
+        parent.splice(key === '-' ? parent.length : Number(key), 0, value);
+      } else if (op === 'remove') {
+        parent.splice(Number(key), 1);
+      } else {
+        parent[Number(key)] = value;
+      }
+    } else if (op === 'remove') {
+      delete parent[key];
+    } else {
+      parent[key] = value;
+    }
+  }
+
+  return root.document;
 }
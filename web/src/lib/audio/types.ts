@@ -5,56 +5,115 @@ export type AudioDevices = {
   timestamp: number;
 };
 
+export type StateSyncMessage = {
+  type: 'snapshot' | 'patch';
+  seq: number;
+  snapshot?: Omit<AudioDevices, 'timestamp'>;
+  patch?: { op: 'add' | 'remove' | 'replace'; path: string; value?: unknown }[];
+};
+
 export type Card = {
   index: number;
+  name: string;
   description: string;
-  bus: AudioDeviceBus;
-  formFactor: AudioDeviceFormFactor;
-  sourceIds: Pick<CardDevice, 'index'>;
-  sinkIds: Pick<CardDevice, 'index'>;
-  profiles: BluetoothAudioDeviceProfile[];
-  activeProfile: BluetoothAudioDeviceProfile;
+  bus: AudioDeviceBus | string;
+  formFactor: AudioDeviceFormFactor | string;
+  sourceIds: number[];
+  sinkIds: number[];
+  profiles: (BluetoothAudioDeviceProfile | string)[];
+  activeProfile: BluetoothAudioDeviceProfile | string;
+  batteryLevel?: number;
 };
 
 export type CardDevice = {
   index: number;
   name: string;
   description: string;
+  state: AudioDeviceState | string;
   isDefault: boolean;
   volume: number;
   isMuted: boolean;
-  bluetoothProtocol: BluetoothProtocol;
+  cardIndex: number;
+  bluetoothProtocol: BluetoothProtocol | string;
+  a2dpCodec: A2DPCodec | string;
+  formFactor: AudioDeviceFormFactor | string;
+  bus: AudioDeviceBus | string;
+  batteryLevel?: number;
 };
 
+// The enums below are sent by their names. A value which go-cctl does not know is sent as the sound server reported
+// it, hence the string in the types of the fields.
+
 export enum AudioDeviceBus {
-  PCI = 1,
-  Bluetooth = 2,
-  USB = 3,
+  Unknown = 'unknown',
+  PCI = 'pci',
+  Bluetooth = 'bluetooth',
+  USB = 'usb',
+  FireWire = 'firewire',
+  ISA = 'isa',
+  HDMI = 'hdmi',
+  DisplayPort = 'displayport',
 }
 
 export enum AudioDeviceFormFactor {
-  Internal = 1,
-  Headphones = 2,
-  Webcam = 3,
-  Headset = 4,
+  Unknown = 'unknown',
+  Internal = 'internal',
+  Headphones = 'headphone',
+  Webcam = 'webcam',
+  Headset = 'headset',
+  Speaker = 'speaker',
+  Microphone = 'microphone',
+  Handset = 'handset',
+  HandsFree = 'hands-free',
+  TV = 'tv',
+  Car = 'car',
+  HiFi = 'hifi',
+  Portable = 'portable',
+  Computer = 'computer',
+}
+
+export enum AudioDeviceState {
+  Unknown = 'unknown',
+  Running = 'running',
+  Idle = 'idle',
+  Suspended = 'suspended',
 }
 
 export enum BluetoothAudioDeviceProfile {
-  HeadsetHeadUnit = 1,
-  A2DPSinkSBC = 2,
-  A2DPSinkAAC = 3,
-  A2DPSinkAptX = 4,
-  A2DPSinkAptXHD = 5,
-  A2DPSinkLDAC = 6,
-  Off = 7,
+  Unknown = 'unknown',
+  HeadsetHeadUnit = 'headset_head_unit',
+  A2DPSinkSBC = 'a2dp_sink_sbc',
+  A2DPSinkAAC = 'a2dp_sink_aac',
+  A2DPSinkAptX = 'a2dp_sink_aptx',
+  A2DPSinkAptXHD = 'a2dp_sink_aptx_hd',
+  A2DPSinkLDAC = 'a2dp_sink_ldac',
+  Off = 'off',
 }
 
 export enum BluetoothProtocol {
-  HeadsetHeadUnit = 1,
-  A2DPSink = 2,
+  Unknown = 'unknown',
+  HeadsetHeadUnit = 'headset_head_unit',
+  A2DPSink = 'a2dp_sink',
 }
 
-export function bluetoothAudioDeviceProfileToString(profile: BluetoothAudioDeviceProfile): string {
+export enum A2DPCodec {
+  Unknown = 'unknown',
+  SBC = 'sbc',
+  AAC = 'aac',
+  AptX = 'aptx',
+  AptXHD = 'aptx_hd',
+  LDAC = 'ldac',
+  LC3 = 'lc3',
+  MSBC = 'msbc',
+  FastStream = 'faststream',
+}
+
+export enum CardDeviceType {
+  Source = 'source',
+  Sink = 'sink',
+}
+
+export function bluetoothAudioDeviceProfileToString(profile: BluetoothAudioDeviceProfile | string): string {
   switch (profile) {
     case BluetoothAudioDeviceProfile.HeadsetHeadUnit:
       return 'HeadsetHeadUnit';
@@ -71,4 +130,6 @@ export function bluetoothAudioDeviceProfileToString(profile: BluetoothAudioDevic
     case BluetoothAudioDeviceProfile.Off:
       return 'Off';
   }
+
+  return profile;
 }
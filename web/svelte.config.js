@@ -1,3 +1,4 @@
+import adapter from '@sveltejs/adapter-static';
 import preprocess from 'svelte-preprocess';
 
 /** @type {import('@sveltejs/kit').Config} */
@@ -12,6 +13,19 @@ const config = {
     // hydrate the <div id="svelte"> element in src/app.html
     target: '#svelte',
 
+    // The UI is embedded in the go-cctl binary, which serves it under /ui, falling back to index.html.
+    adapter: adapter({
+      pages: '../app/web/ui/dist/build',
+      assets: '../app/web/ui/dist/build',
+      fallback: 'index.html',
+    }),
+    paths: {
+      base: '/ui',
+    },
+    // go:embed leaves out directories starting with an underscore, like the default _app.
+    appDir: 'app',
+    ssr: false,
+
     vite: {
       server: {
         host: '0.0.0.0',